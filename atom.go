@@ -0,0 +1,118 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+type AtomFeed struct {
+	Lang     string      `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
+	Title    AtomText    `xml:"http://www.w3.org/2005/Atom title"`
+	Subtitle string      `xml:"http://www.w3.org/2005/Atom subtitle"`
+	Icon     string      `xml:"http://www.w3.org/2005/Atom icon"`
+	Logo     string      `xml:"http://www.w3.org/2005/Atom logo"`
+	Link     []AtomLink  `xml:"http://www.w3.org/2005/Atom link"`
+	Entry    []AtomEntry `xml:"http://www.w3.org/2005/Atom entry"`
+}
+
+type AtomEntry struct {
+	ID        string     `xml:"http://www.w3.org/2005/Atom id"`
+	Title     AtomText   `xml:"http://www.w3.org/2005/Atom title"`
+	Summary   AtomText   `xml:"http://www.w3.org/2005/Atom summary"`
+	Content   AtomText   `xml:"http://www.w3.org/2005/Atom content"`
+	Published string     `xml:"http://www.w3.org/2005/Atom published"`
+	Updated   string     `xml:"http://www.w3.org/2005/Atom updated"`
+	Link      []AtomLink `xml:"http://www.w3.org/2005/Atom link"`
+}
+
+type AtomText struct {
+	Type  string `xml:"type,attr"`
+	Text  string `xml:",chardata"`
+	Inner string `xml:",innerxml"`
+}
+
+type AtomLink struct {
+	Href   string `xml:"href,attr"`
+	Rel    string `xml:"rel,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+}
+
+// XHTML content is embedded as elements rather than escaped text
+func (t AtomText) String() string {
+	if t.Type == "xhtml" {
+		return t.Inner
+	}
+	return t.Text
+}
+
+// Atom is the only format that says whether a title is HTML
+func (t AtomText) plainLine() string {
+	if t.Type == "html" || t.Type == "xhtml" {
+		return renderPlainLine(t.String())
+	}
+	return cleanLine(t.Text)
+}
+
+// Pick the link with the given rel, links without a rel are "alternate"
+func atomLink(links []AtomLink, rel string) string {
+	for _, link := range links {
+		linkRel := link.Rel
+		if len(linkRel) == 0 {
+			linkRel = "alternate"
+		}
+		if linkRel == rel {
+			return strings.TrimSpace(link.Href)
+		}
+	}
+	return ""
+}
+
+// Atom dates are RFC 3339, convert them to the RFC 1123 dates used by RSS
+func atomDate(entry AtomEntry) string {
+	for _, date := range []string{entry.Published, entry.Updated} {
+		t, err := time.Parse(time.RFC3339, strings.TrimSpace(date))
+		if err == nil {
+			return t.Format(time.RFC1123Z)
+		}
+	}
+	return ""
+}
+
+func (a AtomFeed) toRSS() RSSFeed {
+	result := RSSFeed{}
+	result.Channel.Title = a.Title.plainLine()
+	result.Channel.Link = atomLink(a.Link, "alternate")
+	result.Channel.Description = a.Subtitle
+	result.Channel.Language = a.Lang
+	result.Channel.AtomLink = a.Link
+	result.Channel.Image.URL = strings.TrimSpace(a.Logo)
+	if len(result.Channel.Image.URL) == 0 {
+		result.Channel.Image.URL = strings.TrimSpace(a.Icon)
+	}
+
+	for _, entry := range a.Entry {
+		item := RSSItem{
+			Title:       entry.Title.plainLine(),
+			Link:        atomLink(entry.Link, "alternate"),
+			Description: entry.Content.String(),
+			PubDate:     atomDate(entry),
+			GUID:        strings.TrimSpace(entry.ID),
+		}
+		if len(strings.TrimSpace(item.Description)) == 0 {
+			item.Description = entry.Summary.String()
+		}
+		for _, link := range entry.Link {
+			if link.Rel == "enclosure" {
+				item.Enclosure = append(item.Enclosure, RSSEnclosure{
+					URL:    link.Href,
+					Length: link.Length,
+					Type:   link.Type,
+				})
+			}
+		}
+		result.Channel.Item = append(result.Channel.Item, item)
+	}
+
+	return result
+}
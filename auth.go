@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Tavis7/bootdev-gator/internal/database"
+)
+
+// Credentials sent when fetching a feed
+type feedAuth []database.FeedCredential
+
+func (a feedAuth) apply(req *http.Request) {
+	for _, credential := range a {
+		switch credential.Kind {
+		case "basic":
+			username, password, _ := strings.Cut(credential.Value, ":")
+			req.SetBasicAuth(username, password)
+		case "bearer":
+			req.Header.Set("Authorization", "Bearer "+credential.Value)
+		case "header":
+			req.Header.Set(credential.Name, credential.Value)
+		case "cookie":
+			req.Header.Add("Cookie", credential.Value)
+		}
+	}
+}
+
+// Take the credentials back out of a request, for redirects to another site.
+// net/http only does this for some headers, and not for subdomains.
+func (a feedAuth) remove(req *http.Request) {
+	for _, credential := range a {
+		switch credential.Kind {
+		case "basic", "bearer":
+			req.Header.Del("Authorization")
+		case "header":
+			req.Header.Del(credential.Name)
+		case "cookie":
+			req.Header.Del("Cookie")
+		}
+	}
+}
+
+// Describe a credential without giving away the secret
+func describeCredential(credential database.FeedCredential) string {
+	switch credential.Kind {
+	case "basic":
+		username, _, _ := strings.Cut(credential.Value, ":")
+		return fmt.Sprintf("basic (username %v)", username)
+	case "header":
+		return fmt.Sprintf("header %v", credential.Name)
+	}
+	return credential.Kind
+}
+
+func getFeedAuth(s *state, feedID uuid.UUID) (feedAuth, error) {
+	credentials, err := s.database.GetFeedCredentials(context.Background(), feedID)
+	if err != nil {
+		return nil, fmt.Errorf("Getting feed credentials: %w", err)
+	}
+	return feedAuth(credentials), nil
+}
+
+func setFeedCredential(s *state, feedID uuid.UUID, kind, name, value string) error {
+	now := time.Now().UTC()
+	_, err := s.database.SetFeedCredential(context.Background(),
+		database.SetFeedCredentialParams{
+			ID:        uuid.New(),
+			CreatedAt: now,
+			UpdatedAt: now,
+			Kind:      kind,
+			Name:      name,
+			Value:     value,
+			FeedID:    feedID,
+		})
+	return err
+}
+
+// Take the username and password out of a URL so they are not stored with it
+func splitURLCredentials(feedURL string) (string, *url.Userinfo) {
+	u, err := url.Parse(feedURL)
+	if err != nil || u.User == nil {
+		return feedURL, nil
+	}
+	user := u.User
+	u.User = nil
+	return u.String(), user
+}
+
+func handlerAuth(s *state, cmd command) error {
+	feed, err := s.database.GetFeedByURL(context.Background(), cmd.args[0])
+	if err != nil {
+		return err
+	}
+
+	if len(cmd.args) == 1 {
+		credentials, err := s.database.GetFeedCredentials(context.Background(), feed.ID)
+		if err != nil {
+			return err
+		}
+		if len(credentials) == 0 {
+			fmt.Printf(`"%v" has no credentials`+"\n", feed.Name)
+		}
+		for _, credential := range credentials {
+			fmt.Printf("%v\n", describeCredential(credential))
+		}
+		return nil
+	}
+
+	kind := cmd.args[1]
+	args := cmd.args[2:]
+	switch {
+	case kind == "clear" && len(args) == 0:
+		err = s.database.DeleteFeedCredentials(context.Background(), feed.ID)
+	case kind == "basic" && len(args) == 2:
+		if strings.Contains(args[0], ":") {
+			return fmt.Errorf("Usernames cannot contain ':'")
+		}
+		err = setFeedCredential(s, feed.ID, kind, "", args[0]+":"+args[1])
+	case kind == "bearer" && len(args) == 1:
+		err = setFeedCredential(s, feed.ID, kind, "", args[0])
+	case kind == "header" && len(args) == 2:
+		err = setFeedCredential(s, feed.ID, kind, http.CanonicalHeaderKey(args[0]), args[1])
+	case kind == "cookie" && len(args) == 1:
+		err = setFeedCredential(s, feed.ID, kind, "", args[0])
+	default:
+		return fmt.Errorf("Expected one of: basic <username> <password>, " +
+			"bearer <token>, header <name> <value>, cookie <cookie>, clear")
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf(`Updated credentials for "%v"`+"\n", feed.Name)
+
+	return nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Tavis7/bootdev-gator/internal/database"
+)
+
+// How far back `feeds --bandwidth` looks
+const bandwidthReportPeriod = 30 * 24 * time.Hour
+
+// Save how much was downloaded when fetching a feed
+func recordFeedFetch(s *state, feedID uuid.UUID, result fetchResult) error {
+	if result.response == nil {
+		// Nothing was transferred
+		return nil
+	}
+
+	now := time.Now().UTC()
+	_, err := s.database.CreateFeedFetch(context.Background(),
+		database.CreateFeedFetchParams{
+			ID:               uuid.New(),
+			CreatedAt:        now,
+			UpdatedAt:        now,
+			StatusCode:       int32(result.response.StatusCode),
+			ContentEncoding:  result.contentEncoding,
+			BytesTransferred: result.bytesTransferred,
+			BodySize:         int64(len(result.body)),
+			FeedID:           feedID,
+		})
+	if err != nil {
+		return fmt.Errorf("Recording feed fetch: %w", err)
+	}
+
+	return nil
+}
+
+// Sizes are in bytes, over the last bandwidthReportPeriod
+type bandwidthRecord struct {
+	Name             string `json:"name"`
+	URL              string `json:"url"`
+	Fetches          int64  `json:"fetches"`
+	BytesTransferred int64  `json:"bytes_transferred"`
+	BodySize         int64  `json:"body_size"`
+}
+
+func printFeedBandwidth(s *state) error {
+	since := time.Now().UTC().Add(-bandwidthReportPeriod)
+	feeds, err := s.database.GetFeedBandwidth(context.Background(), since)
+	if err != nil {
+		return err
+	}
+
+	records := []bandwidthRecord{}
+	total := int64(0)
+	for _, feed := range feeds {
+		total += feed.BytesTransferred
+		records = append(records, bandwidthRecord{
+			Name:             feed.Name,
+			URL:              feed.Url,
+			Fetches:          feed.Fetches,
+			BytesTransferred: feed.BytesTransferred,
+			BodySize:         feed.BodySize,
+		})
+	}
+
+	err = printRecords(s, records, func(feed bandwidthRecord) error {
+		saved := ""
+		if feed.BodySize > 0 && feed.BytesTransferred < feed.BodySize {
+			saved = fmt.Sprintf(" (%v uncompressed, %.0f%% saved)", formatBytes(feed.BodySize),
+				100*(1-float64(feed.BytesTransferred)/float64(feed.BodySize)))
+		}
+		fmt.Printf(`"%v": %v`+"\n    %v fetches, %v transferred%v\n",
+			feed.Name, feed.URL, feed.Fetches, formatBytes(feed.BytesTransferred), saved)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Left out of structured output, where it is easily added up
+	if s.output == "text" {
+		fmt.Printf("Total over the last %v days: %v\n",
+			int(bandwidthReportPeriod.Hours()/24), formatBytes(total))
+	}
+
+	return nil
+}
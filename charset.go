@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"mime"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html/charset"
+)
+
+var xmlEncodingDeclaration = regexp.MustCompile(
+	`^(\s*<\?xml[^>]*?\sencoding\s*=\s*)("[^"]*"|'[^']*')`)
+
+// Convert a document to UTF-8 if its byte order mark or the HTTP
+// Content-Type says it is in some other charset. Both take priority over the
+// XML declaration, which is rewritten to match.
+func decodeCharset(data []byte, contentType string) ([]byte, error) {
+	// A byte order mark trumps everything else
+	data, label := stripBOM(data)
+	if len(label) == 0 {
+		_, params, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			return data, nil
+		}
+		label = strings.TrimSpace(params["charset"])
+	}
+	if len(label) == 0 {
+		return data, nil
+	}
+
+	encoding, name := charset.Lookup(label)
+	if encoding == nil {
+		return nil, fmt.Errorf("Unknown charset '%v'", label)
+	}
+	if name != "utf-8" {
+		decoded, err := encoding.NewDecoder().Bytes(data)
+		if err != nil {
+			return nil, fmt.Errorf("Decoding %v: %w", name, err)
+		}
+		data = decoded
+	}
+
+	// Even if the data was UTF-8 already, the declaration may say otherwise
+	return xmlEncodingDeclaration.ReplaceAll(data, []byte(`${1}"UTF-8"`)), nil
+}
+
+// Remove a byte order mark, returning the charset it stands for
+func stripBOM(data []byte) ([]byte, string) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		return data[3:], "utf-8"
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		return data[2:], "utf-16be"
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		return data[2:], "utf-16le"
+	}
+	return data, ""
+}
+
+// Like xml.NewDecoder, but able to read documents that declare a charset
+// other than UTF-8. A lenient decoder also accepts HTML entities and stray
+// ampersands.
+func newXMLDecoder(data []byte, lenient bool) *xml.Decoder {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.CharsetReader = charset.NewReaderLabel
+	if lenient {
+		decoder.Strict = false
+		decoder.Entity = xml.HTMLEntity
+	}
+	return decoder
+}
@@ -2,14 +2,24 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/lib/pq"
 
 	"github.com/Tavis7/bootdev-gator/internal/database"
 )
@@ -17,14 +27,22 @@ import (
 type command struct {
 	name string
 	args []string
+	// Values of the command's flags, by name, see flags.go
+	flags map[string]any
 }
 
 type commandDoc struct {
-	name string
-	args string
-	doc  string
+	name  string
+	args  string
+	doc   string
+	flags []flagDef
+	// Left out of help, for commands only meant to be run by scripts
+	hidden bool
 }
 
+// Width that post descriptions are wrapped to by browse
+const browseTextWidth = 80
+
 type commands struct {
 	commandList         map[string]func(*state, command) error
 	commandDocs         []commandDoc
@@ -32,10 +50,6 @@ type commands struct {
 }
 
 func handlerLogin(s *state, cmd command) error {
-	if len(cmd.args) != 1 {
-		return fmt.Errorf("username expected")
-	}
-
 	username := cmd.args[0]
 
 	user, err := s.database.GetUser(context.Background(), username)
@@ -54,9 +68,6 @@ func handlerLogin(s *state, cmd command) error {
 }
 
 func handlerRegister(s *state, cmd command) error {
-	if len(cmd.args) != 1 {
-		return fmt.Errorf("Username expected")
-	}
 	username := cmd.args[0]
 	fmt.Printf("Registering %v\n", username)
 
@@ -79,32 +90,36 @@ func handlerRegister(s *state, cmd command) error {
 	return nil
 }
 
-func handlerUsers(s *state, cmd command) error {
-	if len(cmd.args) != 0 {
-		return fmt.Errorf("No arguments expected")
-	}
+type userRecord struct {
+	Name    string `json:"name"`
+	Current bool   `json:"current"`
+}
 
+func handlerUsers(s *state, cmd command) error {
 	users, err := s.database.GetUsers(context.Background())
 	if err != nil {
 		return err
 	}
 
+	records := []userRecord{}
 	for _, user := range users {
+		records = append(records, userRecord{
+			Name:    user,
+			Current: user == s.config.Current_user_name,
+		})
+	}
+
+	return printRecords(s, records, func(user userRecord) error {
 		current := ""
-		if user == s.config.Current_user_name {
+		if user.Current {
 			current = " (current)"
 		}
-		fmt.Printf("%v%v\n", user, current)
-	}
-
-	return nil
+		fmt.Printf("%v%v\n", user.Name, current)
+		return nil
+	})
 }
 
 func handlerResetUsers(s *state, cmd command) error {
-	if len(cmd.args) != 0 {
-		return fmt.Errorf("No arguments expected")
-	}
-
 	err := s.database.DeleteAllUsers(context.Background())
 	if err != nil {
 		return err
@@ -116,43 +131,55 @@ func handlerResetUsers(s *state, cmd command) error {
 }
 
 func handlerAgg(s *state, cmd command) error {
-	if len(cmd.args) != 1 {
-		return fmt.Errorf("Exactly one argument expected")
-	}
-
 	timeBetweenRequests, err := time.ParseDuration(cmd.args[0])
 	if err != nil {
 		return err
 	}
 
-	ticker := time.NewTicker(timeBetweenRequests)
-	for ; ; <-ticker.C {
-		fmt.Printf("Scraping feed\n")
-		err = scrapeFeeds(s)
+	if metricsAddress := cmd.stringFlag("metrics"); len(metricsAddress) > 0 {
+		listener, err := net.Listen("tcp", metricsAddress)
 		if err != nil {
 			return err
 		}
+		slog.Info("Serving metrics", "url", fmt.Sprintf("http://%v/metrics", listener.Addr()))
+		mux := http.NewServeMux()
+		mux.Handle("GET /metrics", newMetricsHandler(s))
+		server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			err := server.Serve(listener)
+			if err != nil {
+				slog.Error("Metrics server stopped", "error", err)
+			}
+		}()
 	}
 
-	feedURL := "https://www.wagslane.dev/index.xml"
-
-	feed, err := fetchFeed(context.Background(), feedURL)
-	if err != nil {
-		return err
+	// Errors are logged by scrapeFeeds, and a feed that failed is retried
+	// when it is next due, so one bad feed does not stop the others
+	ticker := time.NewTicker(timeBetweenRequests)
+	for attempt := 1; ; attempt++ {
+		scrapeFeeds(s, slog.With("attempt", attempt))
+		<-ticker.C
 	}
-
-	fmt.Printf("%v\n", feed)
-
-	return nil
 }
 
 func handlerAddFeed(s *state, cmd command, user database.User) error {
-	if len(cmd.args) != 2 {
-		return fmt.Errorf("Exactly two arguments expected")
+	feedName := cmd.args[0]
+
+	// Take the credentials out first, so they are used to find the feed but
+	// never printed
+	pageURL, userinfo := splitURLCredentials(cmd.args[1])
+	var auth feedAuth
+	credential := ""
+	if userinfo != nil {
+		password, _ := userinfo.Password()
+		credential = userinfo.Username() + ":" + password
+		auth = feedAuth{{Kind: "basic", Value: credential}}
 	}
 
-	feedName := cmd.args[0]
-	feedURL := cmd.args[1]
+	feedURL, err := resolveFeedURL(context.Background(), s.fetcher, pageURL, auth, s.input)
+	if err != nil {
+		return err
+	}
 
 	fmt.Printf("Adding feed %v @ %v for %v\n", feedName, feedURL, user.Name)
 
@@ -172,12 +199,47 @@ func handlerAddFeed(s *state, cmd command, user database.User) error {
 
 	fmt.Printf("Added feed %v\n", feed)
 
+	if len(credential) > 0 {
+		// As when fetching, credentials do not follow a feed to another site
+		page, pageErr := url.Parse(pageURL)
+		resolved, resolvedErr := url.Parse(feedURL)
+		if pageErr != nil || resolvedErr != nil || !sameOrigin(page, resolved) {
+			fmt.Printf("Not saving the username and password from the URL, " +
+				"the feed is on another site. Use the auth command to add credentials for it.\n")
+		} else {
+			err = setFeedCredential(s, feed.ID, "basic", "", credential)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Saved the username and password from the URL as credentials\n")
+		}
+	}
+
 	return helperFollow(s, feed.ID, user.ID)
 }
 
+// Credentials are described without their secrets
+type feedRecord struct {
+	Name        string   `json:"name"`
+	URL         string   `json:"url"`
+	CreatedBy   string   `json:"created_by"`
+	Title       string   `json:"title"`
+	SiteURL     string   `json:"site_url"`
+	Language    string   `json:"language"`
+	ImageURL    string   `json:"image_url"`
+	Description string   `json:"description"`
+	Auth        []string `json:"auth"`
+}
+
 func handlerListFeeds(s *state, cmd command) error {
-	if len(cmd.args) != 0 {
-		return fmt.Errorf("No arguments expected")
+	if cmd.boolFlag("schedule") && cmd.boolFlag("bandwidth") {
+		return fmt.Errorf("Only one of --schedule and --bandwidth can be given")
+	}
+	if cmd.boolFlag("schedule") {
+		return printFeedSchedule(s)
+	}
+	if cmd.boolFlag("bandwidth") {
+		return printFeedBandwidth(s)
 	}
 
 	feeds, err := s.database.GetFeeds(context.Background())
@@ -185,11 +247,51 @@ func handlerListFeeds(s *state, cmd command) error {
 		return err
 	}
 
+	records := []feedRecord{}
 	for _, feed := range feeds {
-		fmt.Printf(`"%v": %v (created by %v)`+"\n", feed.Name, feed.Url, feed.Username.String)
+		credentials, err := s.database.GetFeedCredentials(context.Background(), feed.ID)
+		if err != nil {
+			return err
+		}
+		auth := []string{}
+		for _, credential := range credentials {
+			auth = append(auth, describeCredential(credential))
+		}
+		records = append(records, feedRecord{
+			Name:        feed.Name,
+			URL:         feed.Url,
+			CreatedBy:   feed.Username.String,
+			Title:       feed.Title.String,
+			SiteURL:     feed.SiteUrl.String,
+			Language:    feed.Language.String,
+			ImageURL:    feed.ImageUrl.String,
+			Description: feed.Description.String,
+			Auth:        auth,
+		})
 	}
 
-	return nil
+	return printRecords(s, records, func(feed feedRecord) error {
+		fmt.Printf(`"%v": %v (created by %v)`+"\n", feed.Name, feed.URL, feed.CreatedBy)
+		if len(feed.Title) > 0 && feed.Title != feed.Name {
+			fmt.Printf("    title: %v\n", feed.Title)
+		}
+		if len(feed.SiteURL) > 0 {
+			fmt.Printf("    site: %v\n", feed.SiteURL)
+		}
+		if len(feed.Language) > 0 {
+			fmt.Printf("    language: %v\n", feed.Language)
+		}
+		if len(feed.ImageURL) > 0 {
+			fmt.Printf("    image: %v\n", feed.ImageURL)
+		}
+		if len(feed.Description) > 0 {
+			fmt.Printf("%v\n", strings.Join(wrapText(feed.Description, browseTextWidth, "    "), "\n"))
+		}
+		for _, auth := range feed.Auth {
+			fmt.Printf("    auth: %v\n", auth)
+		}
+		return nil
+	})
 }
 
 func helperFollow(s *state, feed uuid.UUID, user uuid.UUID) error {
@@ -212,10 +314,6 @@ func helperFollow(s *state, feed uuid.UUID, user uuid.UUID) error {
 }
 
 func handlerFollow(s *state, cmd command, user database.User) error {
-	if len(cmd.args) != 1 {
-		return fmt.Errorf("Exactly one argument expected")
-	}
-
 	feedURL := cmd.args[0]
 
 	feed, err := s.database.GetFeedByURL(context.Background(), feedURL)
@@ -226,28 +324,34 @@ func handlerFollow(s *state, cmd command, user database.User) error {
 	return helperFollow(s, feed.ID, user.ID)
 }
 
-func handlerFollowing(s *state, cmd command, user database.User) error {
-	if len(cmd.args) != 0 {
-		return fmt.Errorf("No arguments expected")
-	}
+type followingRecord struct {
+	User string `json:"user"`
+	Feed string `json:"feed"`
+	URL  string `json:"url"`
+}
 
+func handlerFollowing(s *state, cmd command, user database.User) error {
 	feeds, err := s.database.GetFeedFollowsForUser(context.Background(), user.ID)
 	if err != nil {
 		return err
 	}
 
+	records := []followingRecord{}
 	for _, feed := range feeds {
-		fmt.Printf(`%v is following "%v" @ %v`+"\n", feed.Username, feed.Feedname, feed.FeedUrl)
+		records = append(records, followingRecord{
+			User: feed.Username,
+			Feed: feed.Feedname,
+			URL:  feed.FeedUrl,
+		})
 	}
 
-	return nil
+	return printRecords(s, records, func(feed followingRecord) error {
+		fmt.Printf(`%v is following "%v" @ %v`+"\n", feed.User, feed.Feed, feed.URL)
+		return nil
+	})
 }
 
 func handlerUnfollow(s *state, cmd command, user database.User) error {
-	if len(cmd.args) != 1 {
-		return fmt.Errorf("Exactly one argument expected")
-	}
-
 	feedURL := cmd.args[0]
 
 	feed, err := s.database.GetFeedByURL(context.Background(), feedURL)
@@ -267,61 +371,167 @@ func handlerUnfollow(s *state, cmd command, user database.User) error {
 	return nil
 }
 
-func handlerBrowse(s *state, cmd command, user database.User) error {
-	if len(cmd.args) > 1 {
-		return fmt.Errorf("Only one argument expected")
-	}
+// The description is left as the feed gave it, which may be HTML
+type postRecord struct {
+	ID          string            `json:"id"`
+	PublishedAt time.Time         `json:"published_at"`
+	Feed        string            `json:"feed"`
+	Title       string            `json:"title"`
+	URL         string            `json:"url"`
+	Updated     bool              `json:"updated"`
+	Description string            `json:"description"`
+	Enclosures  []enclosureRecord `json:"enclosures"`
+}
 
-	limit := int32(2)
+func handlerBrowse(s *state, cmd command, user database.User) error {
+	limit := cmd.intFlag("limit")
+	// The limit used to be an argument rather than --limit
 	if len(cmd.args) == 1 {
-		l, err := strconv.ParseInt(cmd.args[0], 10, 32)
+		var err error
+		limit, err = strconv.Atoi(cmd.args[0])
 		if err != nil {
-			return err
+			return fmt.Errorf("Invalid limit '%v'", cmd.args[0])
 		}
-		limit = int32(l)
+	}
+	if limit < 1 || limit > math.MaxInt32 {
+		return fmt.Errorf("--limit must be a positive number")
 	}
 
 	feed, err := s.database.GetPostsForUser(context.Background(),
 		database.GetPostsForUserParams{
 			ID:    user.ID,
-			Limit: limit,
+			Limit: int32(limit),
 		})
 	if err != nil {
 		return err
 	}
 
+	records := []postRecord{}
 	for _, item := range feed {
-		fmt.Printf(`[%v] "%v": "%v"`+"\n    %v\n",
-			item.PublishedAt, item.FeedName, item.Title.String, item.Url)
+		enclosures, err := s.database.GetEnclosuresForPost(context.Background(), item.ID)
+		if err != nil {
+			return err
+		}
+		enclosureRecords := []enclosureRecord{}
+		for _, e := range enclosures {
+			enclosureRecords = append(enclosureRecords, newEnclosureRecord(e))
+		}
+		records = append(records, postRecord{
+			ID:          item.ID.String(),
+			PublishedAt: item.PublishedAt,
+			Feed:        item.FeedName,
+			Title:       item.Title.String,
+			URL:         item.Url,
+			Updated:     item.Revisions > 0,
+			Description: item.Description.String,
+			Enclosures:  enclosureRecords,
+		})
 	}
 
-	return nil
+	return printRecords(s, records, func(item postRecord) error {
+		updated := ""
+		if item.Updated {
+			updated = " (updated)"
+		}
+		fmt.Printf(`[%v] "%v": "%v"%v`+"\n    %v\n",
+			item.PublishedAt, item.Feed, item.Title, updated, item.URL)
+		for _, e := range item.Enclosures {
+			fmt.Printf("    enclosure: %v\n", e)
+		}
+		if len(item.Description) > 0 {
+			fmt.Printf("\n%v\n\n",
+				renderPlainText(item.Description, browseTextWidth, "    "))
+		}
+		return nil
+	})
 }
 
 func handlerHelp(s *state, cmd command) error {
-	if len(cmd.args) != 0 {
-		return fmt.Errorf("No arguments expected")
+	if len(cmd.args) == 1 {
+		return printCommandHelp(s, cmd.args[0])
 	}
 
 	fmt.Printf("Available commands:\n")
 	for _, doc := range s.commands.commandDocs {
-		docstring := doc.name
-		if len(doc.args) > 0 {
-			docstring += " " + doc.args
+		if doc.hidden {
+			continue
 		}
+		docstring := commandUsage(doc.name, doc.args, doc.flags)
 		length := len(docstring)
 		fmt.Printf("    %v: %v%v\n", docstring,
 			strings.Repeat(" ", max(s.commands.maxCommandArgLength-length+1, 0)), doc.doc)
 	}
 
+	fmt.Printf("\nGlobal options, which work with every command:\n")
+	err := writeFlagDocs(os.Stdout, globalFlags)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("\nRun `help <command>` for more about a command\n")
+
 	return nil
 }
 
+func printCommandHelp(s *state, name string) error {
+	doc, ok := s.commands.doc(name)
+	if !ok {
+		return fmt.Errorf("Command '%v' does not exist", name)
+	}
+
+	fmt.Printf("Usage: %v [global options] %v\n\n%v\n",
+		filepath.Base(os.Args[0]), commandUsage(doc.name, doc.args, doc.flags), doc.doc)
+
+	minArgs, maxArgs := argCounts(doc.args)
+	switch {
+	case maxArgs == 0:
+		fmt.Printf("\nTakes no arguments\n")
+	case maxArgs < 0:
+		fmt.Printf("\nTakes at least %v argument(s)\n", minArgs)
+	case minArgs == maxArgs:
+		fmt.Printf("\nTakes %v argument(s)\n", minArgs)
+	default:
+		fmt.Printf("\nTakes %v to %v arguments\n", minArgs, maxArgs)
+	}
+
+	if len(doc.flags) > 0 {
+		fmt.Printf("\nOptions:\n")
+		err := writeFlagDocs(os.Stdout, doc.flags)
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("\nGlobal options:\n")
+	return writeFlagDocs(os.Stdout, globalFlags)
+}
+
+func (c *commands) doc(name string) (commandDoc, bool) {
+	for _, doc := range c.commandDocs {
+		if doc.name == name {
+			return doc, true
+		}
+	}
+	return commandDoc{}, false
+}
+
+// Parse the command's flags and check it has the right number of arguments
+// before running it
 func (c *commands) run(s *state, cmd command) error {
 	f, ok := c.commandList[cmd.name]
 	if !ok {
 		return fmt.Errorf("Command '%v' does not exist", cmd.name)
 	}
+	doc, _ := c.doc(cmd.name)
+
+	flags, args, err := parseFlags(doc.flags, cmd.args, false)
+	if err == nil {
+		err = checkArgCount(doc.args, args)
+	}
+	if err != nil {
+		return fmt.Errorf("%w, see `help %v`", err, cmd.name)
+	}
+	cmd.flags = flags
+	cmd.args = args
 
 	return f(s, cmd)
 }
@@ -343,11 +553,17 @@ func parseDate(dateString string) (time.Time, error) {
 
 	dateString = strings.Trim(dateString, " ")
 	parts := strings.Fields(dateString)
+	if len(parts) < 5 {
+		return time.Time{}, fmt.Errorf("Unrecognised date '%v'", dateString)
+	}
 	offset := 0
 	if len(parts[0]) > 2 {
 		day = "Mon, "
 		offset = 1
 	}
+	if len(parts) < 5+offset {
+		return time.Time{}, fmt.Errorf("Unrecognised date '%v'", dateString)
+	}
 	if len(parts[2+offset]) > 2 {
 		year = "2006"
 	}
@@ -360,16 +576,95 @@ func parseDate(dateString string) (time.Time, error) {
 	return date, nil
 }
 
-func createPost(s *state, item RSSItem, feedID uuid.UUID) error {
-	// fmt.Printf("      Creating post...\n")
+// Items are identified by their <guid> or Atom <id>, falling back to the
+// link, and failing that a hash of the content
+func itemGUID(item RSSItem) string {
+	guid := strings.TrimSpace(item.GUID)
+	if len(guid) > 0 {
+		return guid
+	}
+	link := strings.TrimSpace(item.Link)
+	if len(link) > 0 {
+		return link
+	}
+	hash := sha256.Sum256([]byte(item.Title + "\n" + item.Description))
+	return "sha256:" + hex.EncodeToString(hash[:])
+}
+
+func contentHash(title, url, description string) string {
+	hash := sha256.Sum256([]byte(title + "\n" + url + "\n" + description))
+	return hex.EncodeToString(hash[:])
+}
+
+// Save an item as a post, returning whether it is new
+func createPost(s *state, item RSSItem, feedID uuid.UUID) (bool, error) {
 	now := time.Now().UTC()
 	publishedAt, err := parseDate(item.PubDate)
 	if err != nil {
-		return err
+		return false, err
+	}
+
+	guid := itemGUID(item)
+	hash := contentHash(item.Title, item.Link, item.Description)
+
+	// The post is locked until it is saved, so that a refresh running at the
+	// same time as the daemon does not record the same revision twice
+	tx, err := s.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	queries := queriesInTx(tx)
+
+	// Keep the previous version around if the publisher has changed the post
+	existing, err := queries.GetPostByGUID(context.Background(),
+		database.GetPostByGUIDParams{
+			FeedID: feedID,
+			Guid:   guid,
+		})
+	if errors.Is(err, sql.ErrNoRows) && guid != item.Link {
+		// Posts saved before guids were used had their URL as their guid
+		existing, err = queries.GetPostByGUID(context.Background(),
+			database.GetPostByGUIDParams{
+				FeedID: feedID,
+				Guid:   item.Link,
+			})
+		if err == nil {
+			err = queries.UpdatePostGUID(context.Background(),
+				database.UpdatePostGUIDParams{
+					ID:   existing.ID,
+					Guid: guid,
+				})
+			if err != nil {
+				return false, fmt.Errorf("Updating post guid: %w", err)
+			}
+		}
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, err
+	}
+	isNew := errors.Is(err, sql.ErrNoRows)
+	// Hashes from before descriptions were sanitized never match, so those
+	// posts are brought up to date without counting as changed
+	if !isNew && existing.ContentHash != hash && !existing.LegacyHash {
+		_, err = queries.CreatePostRevision(context.Background(),
+			database.CreatePostRevisionParams{
+				ID:          uuid.New(),
+				CreatedAt:   now,
+				UpdatedAt:   now,
+				Title:       existing.Title,
+				Url:         existing.Url,
+				Description: existing.Description,
+				ContentHash: existing.ContentHash,
+				PostID:      existing.ID,
+			})
+		if err != nil {
+			return false, fmt.Errorf("Saving post revision: %w", err)
+		}
 	}
 
-	_, err = s.database.CreatePost(context.Background(),
-		database.CreatePostParams{
+	post, err := queries.UpsertPost(context.Background(),
+		database.UpsertPostParams{
 			ID:          uuid.New(),
 			CreatedAt:   now,
 			UpdatedAt:   now,
@@ -378,63 +673,199 @@ func createPost(s *state, item RSSItem, feedID uuid.UUID) error {
 			Description: sql.NullString{String: item.Description, Valid: true},
 			PublishedAt: publishedAt,
 			FeedID:      feedID,
+			Guid:        guid,
+			ContentHash: hash,
 		})
 	if err != nil {
-		perr, ok := err.(*pq.Error)
-		if !ok ||
-			(perr.Code.Name() != "unique_violation") ||
-			(perr.Constraint != "posts_url_key") {
-			return err
-		}
+		return false, err
 	}
 
-	return nil
+	err = tx.Commit()
+	if err != nil {
+		return false, err
+	}
+
+	return isNew, createEnclosures(s, item, post.ID)
 }
 
-func scrapeFeeds(s *state) error {
-	dbFeed, err := s.database.GetStalestFeed(context.Background())
+// Save what the publisher says about their feed
+func updateFeedMetadata(s *state, feedID uuid.UUID, feed RSSFeed) error {
+	nullString := func(value string) sql.NullString {
+		value = strings.TrimSpace(value)
+		return sql.NullString{String: value, Valid: len(value) > 0}
+	}
+
+	_, err := s.database.UpdateFeedMetadata(context.Background(),
+		database.UpdateFeedMetadataParams{
+			Title:       nullString(feed.Channel.Title),
+			SiteUrl:     nullString(feed.Channel.Link),
+			Description: nullString(feed.Channel.Description),
+			Language:    nullString(feed.Channel.Language),
+			ImageUrl:    nullString(feed.imageURL()),
+			UpdatedAt:   time.Now().UTC(),
+			ID:          feedID,
+		})
 	if err != nil {
-		return fmt.Errorf("Getting stale feed: %w", err)
+		return fmt.Errorf("Updating feed metadata: %w", err)
 	}
 
+	return nil
+}
+
+// Scrape the next feed that is due, returning whether there was one
+func scrapeFeeds(s *state, logger *slog.Logger) (bool, error) {
 	now := time.Now().UTC()
+	dbFeed, err := s.database.GetNextFeedToFetch(context.Background(),
+		sql.NullTime{Time: now, Valid: true})
+	if errors.Is(err, sql.ErrNoRows) {
+		logger.Debug("No feeds are due to be fetched")
+		return false, nil
+	}
+	if err != nil {
+		err = fmt.Errorf("Getting next feed to fetch: %w", err)
+		logger.Error("Scraping failed", "error", err)
+		return false, err
+	}
+
+	logger = feedLogger(logger, dbFeed.ID, dbFeed.Url)
+	_, err = scrapeFeed(s, logger, dbFeed, now)
+	if err != nil {
+		logger.Error("Scraping feed failed", "error", err)
+		return true, err
+	}
+
+	return true, nil
+}
+
+// What happened when a feed was scraped
+type scrapeResult struct {
+	title    string
+	items    int
+	newItems int
+	warnings []string
+}
+
+// Fetch a feed, save its posts and work out when to fetch it next
+func scrapeFeed(s *state, logger *slog.Logger, dbFeed database.Feed, now time.Time) (scraped scrapeResult, err error) {
+	scraped = scrapeResult{title: dbFeed.Name}
+
 	_, err = s.database.MarkFeedFetched(context.Background(),
 		database.MarkFeedFetchedParams{
 			LastFetchedAt: sql.NullTime{Time: now, Valid: true},
 			ID:            dbFeed.ID,
 		})
 	if err != nil {
-		return fmt.Errorf("Marking feed fetched: %w", err)
+		return scraped, fmt.Errorf("Marking feed fetched: %w", err)
 	}
 
+	// Schedule the next fetch however scraping goes, or a feed that fails
+	// part way would be picked again on every tick ahead of all the others
+	feedID := dbFeed.ID
+	var feed RSSFeed
+	var response *http.Response
+	defer func() {
+		err = errors.Join(err, scheduleFeed(s, feedID, feed, response, now))
+	}()
+
 	feedURL := dbFeed.Url
 
-	feed, err := fetchFeed(context.Background(), feedURL)
+	auth, err := getFeedAuth(s, dbFeed.ID)
+	if err != nil {
+		return scraped, err
+	}
+
+	logger.Debug("Fetching feed")
+	start := time.Now()
+	feed, result, err := s.fetcher.fetchFeed(context.Background(), feedURL, auth)
+	response = result.response
+	observeFetch(result, time.Since(start))
+	if result.response != nil {
+		logger.Debug("Fetched feed", "status", result.response.StatusCode,
+			"bytes", result.bytesTransferred, "encoding", result.contentEncoding,
+			"duration", time.Since(start).String())
+	}
+	if errors.Is(err, errParseFeed) {
+		metricParseErrors.add(1, feedURL)
+	}
+	if err != nil {
+		recordErr := recordFeedFetch(s, dbFeed.ID, result)
+		return scraped, errors.Join(fmt.Errorf("Fetching feed: %w", err), recordErr)
+	}
+
+	if len(result.movedTo) > 0 && result.movedTo != feedURL {
+		movedID, err := moveFeed(s, logger, dbFeed, result.movedTo)
+		if err != nil {
+			return scraped, fmt.Errorf("Moving feed: %w", err)
+		}
+		feedID = movedID
+	}
+
+	// After any move, so the fetch is not lost if the feed was merged
+	err = recordFeedFetch(s, feedID, result)
 	if err != nil {
-		return fmt.Errorf("Fetching feed: %w", err)
+		return scraped, err
 	}
 
-	fmt.Printf(`Articles from "%v"`+"\n", feed.Channel.Title)
+	err = updateFeedMetadata(s, feedID, feed)
+	if err != nil {
+		return scraped, err
+	}
+
+	scraped.title = feed.Channel.Title
+	scraped.warnings = slices.Clone(feed.warnings)
+	for _, warning := range feed.warnings {
+		logger.Warn(warning)
+	}
+
+	metricItemsParsed.add(float64(len(feed.Channel.Item)))
 	for _, item := range feed.Channel.Item {
-		/*
-			if len(item.Title) > 0 {
-				fmt.Printf(` - "%v"`+"\n", item.Title)
-			} else {
-				fmt.Printf(" - %#v\n", item)
-			}
-		*/
-		err := createPost(s, item, dbFeed.ID)
+		// One bad item should not stop the rest being saved
+		isNew, err := createPost(s, item, feedID)
 		if err != nil {
-			return err
+			warning := fmt.Sprintf(`Item "%v" could not be saved: %v`, item.Title, err)
+			logger.Warn(warning, "guid", itemGUID(item))
+			scraped.warnings = append(scraped.warnings, warning)
+			continue
+		}
+		logger.Debug("Saved item", "title", item.Title, "guid", itemGUID(item), "new", isNew)
+		scraped.items++
+		if isNew {
+			scraped.newItems++
+			metricItemsInserted.add(1)
+		} else {
+			metricItemsDuplicated.add(1)
 		}
 	}
-	fmt.Printf("---\n")
 
-	return nil
+	logger.Info("Scraped feed", "title", scraped.title,
+		"items", scraped.items, "new_items", scraped.newItems,
+		"duration", time.Since(start).String())
+
+	return scraped, nil
 }
 
-func (c *commands) register(name, args, doc string, f func(*state, command) error) {
+// Add a command. args describes its arguments, as in "<name> [<url>...]",
+// and is used to check how many it is given.
+func (c *commands) register(name, args, doc string, f func(*state, command) error, flags ...flagDef) {
 	c.commandList[name] = f
-	c.commandDocs = append(c.commandDocs, commandDoc{name: name, args: args, doc: doc})
-	c.maxCommandArgLength = max(c.maxCommandArgLength, len(name)+len(args))
+	c.commandDocs = append(c.commandDocs,
+		commandDoc{name: name, args: args, doc: doc, flags: flags})
+	c.maxCommandArgLength = max(c.maxCommandArgLength, len(commandUsage(name, args, flags)))
+}
+
+func (c *commands) registerHidden(name, args string, f func(*state, command) error) {
+	c.commandList[name] = f
+	c.commandDocs = append(c.commandDocs, commandDoc{name: name, args: args, hidden: true})
+}
+
+// A one line summary of how to run a command
+func commandUsage(name, args string, flags []flagDef) string {
+	usage := name
+	if len(flags) > 0 {
+		usage += " [options]"
+	}
+	if len(args) > 0 {
+		usage += " " + args
+	}
+	return usage
 }
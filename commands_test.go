@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDate(t *testing.T) {
+	want := time.Date(2026, 1, 7, 12, 30, 45, 0, time.UTC)
+	tests := []struct {
+		date string
+		want time.Time
+		ok   bool
+	}{
+		{"Wed, 07 Jan 2026 12:30:45 +0000", want, true},
+		{"07 Jan 2026 12:30:45 +0000", want, true},
+		{"Wed, 07 Jan 26 12:30:45 +0000", want, true},
+		{"  Wed, 07 Jan 2026 12:30:45 +0000  ", want, true},
+		{"Wed, 07 Jan 2026 07:30:45 -0500", want, true},
+		{"Wed, 07 Jan 2026 12:30:45", time.Time{}, false},
+		{"Wed, 07 Jan", time.Time{}, false},
+		{"2026-01-07T12:30:45Z", time.Time{}, false},
+		{"", time.Time{}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.date, func(t *testing.T) {
+			got, err := parseDate(test.date)
+			if (err == nil) != test.ok {
+				t.Fatalf("parseDate(%q) error is %v, expected ok %v", test.date, err, test.ok)
+			}
+			if !got.Equal(test.want) {
+				t.Errorf("parseDate(%q) = %v, expected %v", test.date, got, test.want)
+			}
+		})
+	}
+}
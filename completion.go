@@ -0,0 +1,301 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/Tavis7/bootdev-gator/internal/config"
+)
+
+// A suggestion printed by __complete, as the value then a tab then the
+// description, if there is one
+type completion struct {
+	value       string
+	description string
+}
+
+// Values suggested for global options
+var flagValueCompletions = map[string][]string{
+	"output":     outputFormats,
+	"log-level":  {"debug", "info", "warn", "error"},
+	"log-format": {"text", "json"},
+}
+
+var authKinds = []string{"basic", "bearer", "header", "cookie", "clear"}
+
+// Suggestions for the arguments of commands, given which argument is being
+// typed, counting from 0
+var argumentCompleters = map[string]func(s *state, position int) ([]completion, error){
+	"login": func(s *state, position int) ([]completion, error) {
+		return completeUsers(s)
+	},
+	"follow":  completeFirst(completeFeedURLs),
+	"events":  completeFirst(completeFeedURLs),
+	"fetch":   completeFirst(completeFeedURLs),
+	"refresh": func(s *state, position int) ([]completion, error) { return completeFeedURLs(s) },
+	"unfollow": completeFirst(func(s *state) ([]completion, error) {
+		return completeFollowedFeedURLs(s)
+	}),
+	"auth": func(s *state, position int) ([]completion, error) {
+		switch position {
+		case 0:
+			return completeFeedURLs(s)
+		case 1:
+			return completeValues(authKinds), nil
+		}
+		return nil, nil
+	},
+	"help": func(s *state, position int) ([]completion, error) {
+		if position > 0 {
+			return nil, nil
+		}
+		return completeCommands(s), nil
+	},
+	"config": func(s *state, position int) ([]completion, error) {
+		switch position {
+		case 0:
+			return completeValues(configActions), nil
+		case 1:
+			return completeValues(config.Keys()), nil
+		}
+		return nil, nil
+	},
+	"completion": func(s *state, position int) ([]completion, error) {
+		if position > 0 {
+			return nil, nil
+		}
+		return completeValues(completionShells), nil
+	},
+}
+
+// Only complete a command's first argument
+func completeFirst(f func(s *state) ([]completion, error)) func(*state, int) ([]completion, error) {
+	return func(s *state, position int) ([]completion, error) {
+		if position > 0 {
+			return nil, nil
+		}
+		return f(s)
+	}
+}
+
+func completeValues(values []string) []completion {
+	completions := []completion{}
+	for _, value := range values {
+		completions = append(completions, completion{value: value})
+	}
+	return completions
+}
+
+func completeCommands(s *state) []completion {
+	completions := []completion{}
+	for _, doc := range s.commands.commandDocs {
+		if !doc.hidden {
+			completions = append(completions, completion{value: doc.name, description: doc.doc})
+		}
+	}
+	return completions
+}
+
+func completeFlags(defs []flagDef) []completion {
+	completions := []completion{}
+	for _, def := range defs {
+		completions = append(completions, completion{value: "--" + def.name, description: def.doc})
+	}
+	return completions
+}
+
+func completeUsers(s *state) ([]completion, error) {
+	users, err := s.database.GetUsers(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return completeValues(users), nil
+}
+
+func completeFeedURLs(s *state) ([]completion, error) {
+	feeds, err := s.database.GetFeeds(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	completions := []completion{}
+	for _, feed := range feeds {
+		completions = append(completions, completion{value: feed.Url, description: feed.Name})
+	}
+	return completions, nil
+}
+
+func completeFollowedFeedURLs(s *state) ([]completion, error) {
+	user, err := s.database.GetUser(context.Background(), s.config.Current_user_name)
+	if err != nil {
+		return nil, err
+	}
+	feeds, err := s.database.GetFeedFollowsForUser(context.Background(), user.ID)
+	if err != nil {
+		return nil, err
+	}
+	completions := []completion{}
+	for _, feed := range feeds {
+		completions = append(completions, completion{value: feed.FeedUrl, description: feed.Feedname})
+	}
+	return completions, nil
+}
+
+// Suggest what could come next on a partly typed command line. words are
+// the words typed after the program's name, the last being the one under the
+// cursor, which may be empty.
+func completeWords(s *state, words []string) ([]completion, error) {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	current := words[len(words)-1]
+
+	// Work out which command is being typed, and which of its arguments
+	doc, haveCommand := commandDoc{}, false
+	position := 0
+	afterDashes := false
+	var pendingFlag *flagDef
+	for _, word := range words[:len(words)-1] {
+		switch {
+		case pendingFlag != nil:
+			pendingFlag = nil
+		case !afterDashes && word == "--":
+			afterDashes = true
+		case !afterDashes && strings.HasPrefix(word, "--"):
+			name, _, hasValue := strings.Cut(strings.TrimPrefix(word, "--"), "=")
+			def, found := findFlag(slices.Concat(globalFlags, doc.flags), name)
+			if found && def.kind != flagBool && !hasValue {
+				pendingFlag = &def
+			}
+		case !haveCommand:
+			doc, haveCommand = s.commands.doc(word)
+			if !haveCommand {
+				return nil, nil
+			}
+		default:
+			position++
+		}
+	}
+
+	completions := []completion{}
+	switch {
+	case pendingFlag != nil:
+		completions = completeValues(flagValueCompletions[pendingFlag.name])
+	case !afterDashes && strings.HasPrefix(current, "--"):
+		name, _, hasValue := strings.Cut(strings.TrimPrefix(current, "--"), "=")
+		if hasValue {
+			for _, value := range flagValueCompletions[name] {
+				completions = append(completions, completion{value: "--" + name + "=" + value})
+			}
+		} else {
+			completions = completeFlags(slices.Concat(globalFlags, doc.flags))
+		}
+	case !haveCommand:
+		completions = completeCommands(s)
+	case argumentCompleters[doc.name] != nil:
+		var err error
+		completions, err = argumentCompleters[doc.name](s, position)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	matching := []completion{}
+	for _, c := range completions {
+		if strings.HasPrefix(c.value, current) {
+			matching = append(matching, c)
+		}
+	}
+	return matching, nil
+}
+
+// Print suggestions for the scripts printed by `completion`, one per line
+func handlerComplete(s *state, cmd command) error {
+	completions, err := completeWords(s, cmd.args)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range completions {
+		if len(c.description) > 0 {
+			fmt.Printf("%v\t%v\n", c.value, c.description)
+		} else {
+			fmt.Printf("%v\n", c.value)
+		}
+	}
+
+	return nil
+}
+
+var completionShells = []string{"bash", "zsh", "fish"}
+
+// The scripts ask __complete for suggestions, so they stay up to date as
+// commands change. %[1]v is the program's name and %[2]v a version of it
+// that can be used in function names.
+var completionScripts = map[string]string{
+	"bash": `# bash completion for %[1]v, generated by ` + "`%[1]v completion bash`" + `
+_%[2]v_complete() {
+    local line="${COMP_LINE:0:COMP_POINT}"
+    local -a words
+    read -ra words <<< "$line"
+    if [[ -z "$line" || "$line" =~ [[:space:]]$ ]]; then
+        words+=("")
+    fi
+    local cur="${words[${#words[@]}-1]}"
+    # Bash only replaces the part of the word after the last : or =
+    local prefix="${cur%%"${cur##*[:=]}"}"
+    local IFS=$'\n'
+    COMPREPLY=($(%[1]v __complete -- "${words[@]:1}" 2>/dev/null | cut -f1))
+    COMPREPLY=("${COMPREPLY[@]#"$prefix"}")
+}
+complete -F _%[2]v_complete %[1]v
+`,
+	"zsh": `#compdef %[1]v
+# zsh completion for %[1]v, generated by ` + "`%[1]v completion zsh`" + `
+_%[2]v() {
+    local -a candidates
+    local line value
+    for line in "${(@f)$(%[1]v __complete -- "${(@)words[2,CURRENT]}" 2>/dev/null)}"; do
+        [[ -z $line ]] && continue
+        value=${line%%%%$'\t'*}
+        if [[ $value == $line ]]; then
+            candidates+=("${value//:/\\:}")
+        else
+            candidates+=("${value//:/\\:}:${line#*$'\t'}")
+        fi
+    done
+    _describe '%[1]v' candidates
+}
+compdef _%[2]v %[1]v
+`,
+	"fish": `# fish completion for %[1]v, generated by ` + "`%[1]v completion fish`" + `
+function __%[2]v_complete
+    set -l words (commandline -opc)
+    set -l current (commandline -ct)
+    %[1]v __complete -- $words[2..-1] "$current" 2>/dev/null
+end
+complete -c %[1]v -f -a '(__%[2]v_complete)'
+`,
+}
+
+func handlerCompletion(s *state, cmd command) error {
+	script, ok := completionScripts[cmd.args[0]]
+	if !ok {
+		return fmt.Errorf("Unknown shell '%v', expected one of %v",
+			cmd.args[0], strings.Join(completionShells, ", "))
+	}
+
+	name := filepath.Base(os.Args[0])
+	functionName := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, name)
+	fmt.Printf(script, name, functionName)
+
+	return nil
+}
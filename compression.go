@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// Sent with feed requests. Go only decompresses responses by itself when it
+// chose the Accept-Encoding, which leaves out brotli and stops us counting
+// the compressed bytes.
+const acceptEncoding = "gzip, br, deflate"
+
+// Counts the bytes read through it
+type countingReader struct {
+	reader io.Reader
+	n      int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// Undo the Content-Encoding of a response body, returning the encoding that
+// was actually used. Some servers gzip responses without saying so, and
+// some say so without doing it, so gzip is recognised by its magic number
+// rather than the header.
+func decompressBody(body io.Reader, contentEncoding string) (io.Reader, string, error) {
+	buffered := bufio.NewReader(body)
+	magic, _ := buffered.Peek(2)
+
+	if bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		reader, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, "", fmt.Errorf("Decompressing gzip response: %w", err)
+		}
+		return reader, "gzip", nil
+	}
+
+	switch strings.ToLower(strings.TrimSpace(contentEncoding)) {
+	case "br":
+		return brotli.NewReader(buffered), "br", nil
+	case "deflate":
+		// Supposed to be zlib, but plenty of servers send raw deflate
+		if len(magic) == 2 && magic[0]&0x0f == 8 && (int(magic[0])<<8|int(magic[1]))%31 == 0 {
+			reader, err := zlib.NewReader(buffered)
+			if err != nil {
+				return nil, "", fmt.Errorf("Decompressing deflate response: %w", err)
+			}
+			return reader, "deflate", nil
+		}
+		return flate.NewReader(buffered), "deflate", nil
+	}
+
+	return buffered, "", nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+func compress(t *testing.T, newWriter func(io.Writer) io.WriteCloser, data string) []byte {
+	var buf bytes.Buffer
+	w := newWriter(&buf)
+	_, err := io.WriteString(w, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecompressBody(t *testing.T) {
+	const feed = "<rss><channel><title>Compressed</title></channel></rss>"
+	gzipped := compress(t, func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }, feed)
+	zlibbed := compress(t, func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) }, feed)
+	deflated := compress(t, func(w io.Writer) io.WriteCloser {
+		writer, _ := flate.NewWriter(w, flate.DefaultCompression)
+		return writer
+	}, feed)
+	brotlied := compress(t, func(w io.Writer) io.WriteCloser { return brotli.NewWriter(w) }, feed)
+
+	tests := []struct {
+		name     string
+		body     []byte
+		header   string
+		encoding string
+	}{
+		{"identity", []byte(feed), "", ""},
+		{"gzip", gzipped, "gzip", "gzip"},
+		{"undeclared gzip", gzipped, "", "gzip"},
+		{"gzip declared as brotli", gzipped, "br", "gzip"},
+		{"falsely declared gzip", []byte(feed), "gzip", ""},
+		{"brotli", brotlied, "br", "br"},
+		{"brotli header case", brotlied, " BR ", "br"},
+		{"zlib deflate", zlibbed, "deflate", "deflate"},
+		{"raw deflate", deflated, "deflate", "deflate"},
+		{"unknown encoding", []byte(feed), "compress", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reader, encoding, err := decompressBody(bytes.NewReader(test.body), test.header)
+			if err != nil {
+				t.Fatalf("decompressBody: %v", err)
+			}
+			if encoding != test.encoding {
+				t.Errorf("Encoding is %q, expected %q", encoding, test.encoding)
+			}
+			data, err := io.ReadAll(reader)
+			if err != nil {
+				t.Fatalf("Reading body: %v", err)
+			}
+			if string(data) != feed {
+				t.Errorf("Body is %q, expected %q", data, feed)
+			}
+		})
+	}
+}
+
+func TestDecompressBodyTruncatedGzip(t *testing.T) {
+	_, _, err := decompressBody(bytes.NewReader([]byte{0x1f, 0x8b}), "gzip")
+	if err == nil {
+		t.Errorf("Expected an error for a truncated gzip header")
+	}
+}
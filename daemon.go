@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// How long /readyz waits for the database to answer
+const readyCheckTimeout = 5 * time.Second
+
+// What the daemon's scrape loop has been up to, for the health endpoints
+type daemonStatus struct {
+	mutex       sync.Mutex
+	started     time.Time
+	lastAttempt time.Time
+	lastSuccess time.Time
+	// When the daemon last found no feeds due, which also shows that the
+	// database is working
+	lastIdle  time.Time
+	lastError string
+	// The loop is considered stuck if it has not tried to scrape for this long
+	stuckAfter time.Duration
+}
+
+// Record an attempt to scrape. scraped is false if no feed was due.
+func (d *daemonStatus) record(scraped bool, err error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.lastAttempt = time.Now().UTC()
+	if err != nil {
+		d.lastError = err.Error()
+		return
+	}
+	if scraped {
+		d.lastSuccess = d.lastAttempt
+		d.lastError = ""
+	} else {
+		d.lastIdle = d.lastAttempt
+	}
+}
+
+// Whether the scrape loop is still going round
+func (d *daemonStatus) alive() bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	last := d.lastAttempt
+	if last.IsZero() {
+		last = d.started
+	}
+	return time.Since(last) < d.stuckAfter
+}
+
+type healthReport struct {
+	Status      string     `json:"status"`
+	Database    string     `json:"database,omitempty"`
+	Started     time.Time  `json:"started"`
+	LastAttempt *time.Time `json:"last_attempt,omitempty"`
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+	LastIdle    *time.Time `json:"last_idle,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+}
+
+func (d *daemonStatus) report() healthReport {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	report := healthReport{
+		Status:    "ok",
+		Started:   d.started,
+		LastError: d.lastError,
+	}
+	if !d.lastAttempt.IsZero() {
+		lastAttempt := d.lastAttempt
+		report.LastAttempt = &lastAttempt
+	}
+	if !d.lastSuccess.IsZero() {
+		lastSuccess := d.lastSuccess
+		report.LastSuccess = &lastSuccess
+	}
+	if !d.lastIdle.IsZero() {
+		lastIdle := d.lastIdle
+		report.LastIdle = &lastIdle
+	}
+	return report
+}
+
+func writeHealthReport(w http.ResponseWriter, report healthReport) {
+	w.Header().Set("Content-Type", "application/json")
+	if report.Status != "ok" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+func newHealthHandler(s *state, status *daemonStatus) http.Handler {
+	mux := http.NewServeMux()
+
+	// Alive as long as the scrape loop has not got stuck
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		report := status.report()
+		if !status.alive() {
+			report.Status = "stuck"
+		}
+		writeHealthReport(w, report)
+	})
+
+	// Ready once the database is reachable and a scrape has succeeded, or
+	// there was nothing to scrape. Otherwise a daemon restarted when no
+	// feeds are due would not be ready until one is.
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, r *http.Request) {
+		report := status.report()
+		ctx, cancel := context.WithTimeout(r.Context(), readyCheckTimeout)
+		defer cancel()
+		err := s.db.PingContext(ctx)
+		report.Database = "ok"
+		if err != nil {
+			report.Database = err.Error()
+			report.Status = "database unavailable"
+		} else if report.LastSuccess == nil && report.LastIdle == nil {
+			report.Status = "no successful scrape yet"
+		}
+		writeHealthReport(w, report)
+	})
+
+	mux.Handle("GET /metrics", newMetricsHandler(s))
+
+	return mux
+}
+
+// Like agg, but meant to be left running as a service. Scrape errors are
+// logged rather than stopping the daemon.
+func handlerDaemon(s *state, cmd command) error {
+	timeBetweenRequests, err := time.ParseDuration(cmd.args[0])
+	if err != nil {
+		return err
+	}
+	if timeBetweenRequests <= 0 {
+		return fmt.Errorf("Delay must be positive")
+	}
+
+	logger := slog.Default()
+
+	pidPath, err := s.config.PIDFile()
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(pidPath), 0700)
+	if err != nil {
+		return err
+	}
+	pid, err := lockPIDFile(pidPath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err := pid.release()
+		if err != nil {
+			logger.Error("Removing PID file", "error", err)
+		}
+	}()
+
+	fetchTimeout, err := s.config.FetchTimeout()
+	if err != nil {
+		return err
+	}
+	status := &daemonStatus{
+		started:    time.Now().UTC(),
+		stuckAfter: 2*timeBetweenRequests + fetchTimeout,
+	}
+
+	listener, err := net.Listen("tcp", s.config.DaemonListenAddress())
+	if err != nil {
+		return err
+	}
+	server := &http.Server{
+		Handler:           newHealthHandler(s, status),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		err := server.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("Health server stopped", "error", err)
+		}
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	logger.Info("Daemon started", "pid", os.Getpid(), "pid_file", pidPath,
+		"listen", listener.Addr().String(), "delay", timeBetweenRequests.String())
+	err = sdNotify("READY=1")
+	if err != nil {
+		logger.Warn("Notifying systemd", "error", err)
+	}
+
+	// Only pat the watchdog while the scrape loop is making progress, so
+	// that systemd restarts a daemon that has hung
+	watchdog := sdWatchdogInterval()
+	if watchdog > 0 {
+		go func() {
+			ticker := time.NewTicker(watchdog / 2)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					if status.alive() {
+						sdNotify("WATCHDOG=1")
+					}
+				}
+			}
+		}()
+	}
+
+	ticker := time.NewTicker(timeBetweenRequests)
+	defer ticker.Stop()
+	for attempt := 1; ; attempt++ {
+		status.record(scrapeFeeds(s, logger.With("attempt", attempt)))
+
+		select {
+		case <-ctx.Done():
+			logger.Info("Daemon stopping")
+			sdNotify("STOPPING=1")
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			return server.Shutdown(shutdownCtx)
+		case <-ticker.C:
+		}
+	}
+}
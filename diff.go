@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type postVersion struct {
+	time        time.Time
+	title       sql.NullString
+	url         string
+	description sql.NullString
+}
+
+func (v postVersion) lines() []string {
+	lines := []string{
+		"Title: " + v.title.String,
+		"URL: " + v.url,
+		"",
+	}
+	text := renderPlainText(v.description.String, browseTextWidth, "")
+	return append(lines, strings.Split(text, "\n")...)
+}
+
+// Line based diff using the longest common subsequence of lines. Unchanged
+// lines are prefixed with "  ", removed lines with "- " and added lines
+// with "+ ".
+func diffLines(a, b []string) []string {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	result := []string{}
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			result = append(result, "  "+a[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			result = append(result, "- "+a[i])
+			i++
+		default:
+			result = append(result, "+ "+b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		result = append(result, "- "+a[i])
+	}
+	for ; j < len(b); j++ {
+		result = append(result, "+ "+b[j])
+	}
+
+	return result
+}
+
+func handlerDiff(s *state, cmd command) error {
+	post, err := getPostByIDOrURL(s, cmd.args[0])
+	if err != nil {
+		return err
+	}
+
+	revisions, err := s.database.GetPostRevisions(context.Background(), post.ID)
+	if err != nil {
+		return err
+	}
+	if len(revisions) == 0 {
+		fmt.Printf(`"%v" has not been updated`+"\n", post.Title.String)
+		return nil
+	}
+
+	// Each revision was replaced at the time it was saved, so the first
+	// version dates from when the post was created
+	versions := []postVersion{}
+	seen := post.CreatedAt
+	for _, revision := range revisions {
+		versions = append(versions, postVersion{
+			time:        seen,
+			title:       revision.Title,
+			url:         revision.Url,
+			description: revision.Description,
+		})
+		seen = revision.CreatedAt
+	}
+	versions = append(versions, postVersion{
+		time:        seen,
+		title:       post.Title,
+		url:         post.Url,
+		description: post.Description,
+	})
+
+	for i := 1; i < len(versions); i++ {
+		fmt.Printf("--- %v\n+++ %v\n", versions[i-1].time, versions[i].time)
+		for _, line := range diffLines(versions[i-1].lines(), versions[i].lines()) {
+			fmt.Printf("%v\n", strings.TrimRight(line, " "))
+		}
+		fmt.Printf("\n")
+	}
+
+	return nil
+}
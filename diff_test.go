@@ -0,0 +1,35 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want []string
+	}{
+		{"same", "a b c", "a b c", []string{"  a", "  b", "  c"}},
+		{"changed", "a b c", "a x c", []string{"  a", "- b", "+ x", "  c"}},
+		{"added", "a c", "a b c", []string{"  a", "+ b", "  c"}},
+		{"removed", "a b c", "a c", []string{"  a", "- b", "  c"}},
+		{"added at end", "a", "a b", []string{"  a", "+ b"}},
+		{"removed at end", "a b", "a", []string{"  a", "- b"}},
+		{"from nothing", "", "a b", []string{"+ a", "+ b"}},
+		{"to nothing", "a b", "", []string{"- a", "- b"}},
+		{"moved", "a b c", "b c a", []string{"- a", "  b", "  c", "+ a"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := diffLines(strings.Fields(test.a), strings.Fields(test.b))
+			if !slices.Equal(got, test.want) {
+				t.Errorf("diffLines(%q, %q) = %q, expected %q", test.a, test.b, got, test.want)
+			}
+		})
+	}
+}
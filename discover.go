@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+type discoveredFeed struct {
+	url      string
+	title    string
+	mimeType string
+}
+
+var feedMimeTypes = map[string]bool{
+	"application/rss+xml":   true,
+	"application/atom+xml":  true,
+	"application/feed+json": true,
+}
+
+// Paths where sites commonly serve their feeds, tried when a page does not
+// advertise any
+var commonFeedPaths = []string{
+	"/feed",
+	"/index.xml",
+	"/feed.xml",
+	"/rss",
+	"/rss.xml",
+	"/atom.xml",
+	"/feed.json",
+}
+
+// Find the feeds advertised by <link rel="alternate"> tags in an HTML page
+func findFeedLinks(page []byte, pageURL *url.URL) []discoveredFeed {
+	base := pageURL
+	result := []discoveredFeed{}
+	tokenizer := html.NewTokenizer(bytes.NewReader(page))
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			return result
+		}
+		if tokenType != html.StartTagToken && tokenType != html.SelfClosingTagToken {
+			continue
+		}
+
+		token := tokenizer.Token()
+		attrs := map[string]string{}
+		for _, attr := range token.Attr {
+			attrs[attr.Key] = strings.TrimSpace(attr.Val)
+		}
+
+		switch token.DataAtom {
+		case atom.Base:
+			u, err := pageURL.Parse(attrs["href"])
+			if err == nil {
+				base = u
+			}
+		case atom.Link:
+			rels := strings.Fields(strings.ToLower(attrs["rel"]))
+			alternate := false
+			for _, rel := range rels {
+				alternate = alternate || rel == "alternate"
+			}
+			mimeType := strings.ToLower(attrs["type"])
+			if !alternate || !feedMimeTypes[mimeType] || len(attrs["href"]) == 0 {
+				continue
+			}
+			u, err := base.Parse(attrs["href"])
+			if err != nil {
+				continue
+			}
+			result = append(result, discoveredFeed{
+				url:      u.String(),
+				title:    attrs["title"],
+				mimeType: mimeType,
+			})
+		}
+	}
+}
+
+// Pages often link the same feed more than once
+func uniqueFeeds(feeds []discoveredFeed) []discoveredFeed {
+	unique := []discoveredFeed{}
+	seen := map[string]bool{}
+	for _, feed := range feeds {
+		if !seen[feed.url] {
+			seen[feed.url] = true
+			unique = append(unique, feed)
+		}
+	}
+	return unique
+}
+
+// Fetch each linked feed, keeping only those that really are feeds. Titles
+// missing from the links are taken from the feeds.
+func checkFeedLinks(ctx context.Context, f *fetcher, feeds []discoveredFeed, auth feedAuth) []discoveredFeed {
+	result := []discoveredFeed{}
+	for _, feed := range feeds {
+		res, err := f.fetch(ctx, feed.url, auth)
+		if err != nil {
+			fmt.Printf("Skipping %v: %v\n", feed.url, err)
+			continue
+		}
+		parsed, err := parseFeed(res.body, res.response.Header.Get("Content-Type"))
+		if err != nil {
+			fmt.Printf("Skipping %v, it is not a feed: %v\n", feed.url, err)
+			continue
+		}
+		if len(feed.title) == 0 {
+			feed.title = parsed.Channel.Title
+		}
+		result = append(result, feed)
+	}
+	return result
+}
+
+// Try the usual feed locations on the site that pageURL belongs to
+func probeFeedPaths(ctx context.Context, f *fetcher, pageURL *url.URL, auth feedAuth) []discoveredFeed {
+	result := []discoveredFeed{}
+	for _, path := range commonFeedPaths {
+		u := url.URL{Scheme: pageURL.Scheme, Host: pageURL.Host, Path: path}
+		res, err := f.fetch(ctx, u.String(), auth)
+		if err != nil {
+			continue
+		}
+		feed, err := parseFeed(res.body, res.response.Header.Get("Content-Type"))
+		if err != nil {
+			continue
+		}
+		result = append(result, discoveredFeed{
+			url:   u.String(),
+			title: feed.Channel.Title,
+		})
+	}
+	return result
+}
+
+// Ask the user which of several feeds they meant
+func chooseFeed(feeds []discoveredFeed, scanner *bufio.Scanner) (discoveredFeed, error) {
+	fmt.Printf("Found %v feeds:\n", len(feeds))
+	for i, feed := range feeds {
+		details := ""
+		if len(feed.title) > 0 {
+			details += fmt.Sprintf(` "%v"`, feed.title)
+		}
+		if len(feed.mimeType) > 0 {
+			details += fmt.Sprintf(" (%v)", feed.mimeType)
+		}
+		fmt.Printf("    %v: %v%v\n", i+1, feed.url, details)
+	}
+
+	for {
+		fmt.Printf("Which feed? [1-%v]: ", len(feeds))
+		if !scanner.Scan() {
+			if scanner.Err() != nil {
+				return discoveredFeed{}, scanner.Err()
+			}
+			return discoveredFeed{}, fmt.Errorf("No feed chosen")
+		}
+		choice, err := strconv.Atoi(strings.TrimSpace(scanner.Text()))
+		if err == nil && choice >= 1 && choice <= len(feeds) {
+			return feeds[choice-1], nil
+		}
+	}
+}
+
+// Resolve a URL that may be a feed or a web page to the URL of a feed. If the
+// page advertises several feeds the user is asked to pick one, reading their
+// answer from in. auth is sent with every request, as it is when the feed is
+// fetched, and may be nil.
+func resolveFeedURL(ctx context.Context, f *fetcher, pageURL string, auth feedAuth, in *bufio.Scanner) (string, error) {
+	result, err := f.fetch(ctx, pageURL, auth)
+	if result.response != nil && (result.response.StatusCode == http.StatusUnauthorized ||
+		result.response.StatusCode == http.StatusForbidden) {
+		if len(auth) > 0 {
+			return "", fmt.Errorf("%v did not accept the credentials: %w", pageURL, err)
+		}
+		// Credentials can only be added once the feed exists
+		fmt.Printf("%v needs credentials, set them with the auth command\n", pageURL)
+		return pageURL, nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	_, err = parseFeed(result.body, result.response.Header.Get("Content-Type"))
+	if err == nil {
+		if len(result.movedTo) > 0 {
+			fmt.Printf("%v has moved to %v\n", pageURL, result.movedTo)
+			return result.movedTo, nil
+		}
+		return pageURL, nil
+	}
+
+	finalURL := result.response.Request.URL
+	feeds := checkFeedLinks(ctx, f, uniqueFeeds(findFeedLinks(result.body, finalURL)), auth)
+	if len(feeds) == 0 {
+		fmt.Printf("%v is not a feed and does not link to one, trying common feed locations\n", pageURL)
+		feeds = probeFeedPaths(ctx, f, finalURL, auth)
+	}
+
+	switch len(feeds) {
+	case 0:
+		return "", fmt.Errorf("No feeds found at %v", pageURL)
+	case 1:
+		fmt.Printf("Found feed %v\n", feeds[0].url)
+		return feeds[0].url, nil
+	}
+
+	feed, err := chooseFeed(feeds, in)
+	if err != nil {
+		return "", err
+	}
+	return feed.url, nil
+}
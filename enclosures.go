@@ -0,0 +1,415 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Tavis7/bootdev-gator/internal/database"
+)
+
+type enclosure struct {
+	url      string
+	mimeType string
+	length   int64
+	duration int32
+}
+
+// Parse an iTunes style duration: seconds, MM:SS or HH:MM:SS
+func parseEnclosureDuration(s string) int32 {
+	s = strings.TrimSpace(s)
+	if len(s) == 0 {
+		return 0
+	}
+
+	seconds := 0.0
+	for _, part := range strings.Split(s, ":") {
+		n, err := strconv.ParseFloat(part, 64)
+		if err != nil || n < 0 {
+			return 0
+		}
+		seconds = seconds*60 + n
+	}
+	return int32(seconds)
+}
+
+func parseEnclosureLength(s string) int64 {
+	n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
+// Collect the enclosures of an item from <enclosure> and <media:content>,
+// merging entries that point at the same URL
+func itemEnclosures(item RSSItem) []enclosure {
+	result := []enclosure{}
+	add := func(e enclosure) {
+		e.url = strings.TrimSpace(e.url)
+		if len(e.url) == 0 {
+			return
+		}
+		for i := range result {
+			if result[i].url != e.url {
+				continue
+			}
+			if len(result[i].mimeType) == 0 {
+				result[i].mimeType = e.mimeType
+			}
+			if result[i].length == 0 {
+				result[i].length = e.length
+			}
+			if result[i].duration == 0 {
+				result[i].duration = e.duration
+			}
+			return
+		}
+		result = append(result, e)
+	}
+
+	for _, e := range item.Enclosure {
+		add(enclosure{
+			url:      e.URL,
+			mimeType: strings.TrimSpace(e.Type),
+			length:   parseEnclosureLength(e.Length),
+		})
+	}
+	media := append(append([]RSSMediaContent{}, item.MediaContent...), item.MediaGroup...)
+	for _, m := range media {
+		add(enclosure{
+			url:      m.URL,
+			mimeType: strings.TrimSpace(m.Type),
+			length:   parseEnclosureLength(m.FileSize),
+			duration: parseEnclosureDuration(m.Duration),
+		})
+	}
+
+	// The iTunes duration describes the episode, which is the first enclosure
+	if len(result) > 0 && result[0].duration == 0 {
+		result[0].duration = parseEnclosureDuration(item.ITunesDuration)
+	}
+
+	return result
+}
+
+// Save an item's enclosures, removing any it no longer has
+func createEnclosures(s *state, item RSSItem, postID uuid.UUID) error {
+	now := time.Now().UTC()
+	urls := []string{}
+	for _, e := range itemEnclosures(item) {
+		urls = append(urls, e.url)
+		_, err := s.database.CreateEnclosure(context.Background(),
+			database.CreateEnclosureParams{
+				ID:        uuid.New(),
+				CreatedAt: now,
+				UpdatedAt: now,
+				Url:       e.url,
+				MimeType:  sql.NullString{String: e.mimeType, Valid: len(e.mimeType) > 0},
+				Length:    sql.NullInt64{Int64: e.length, Valid: e.length > 0},
+				Duration:  sql.NullInt32{Int32: e.duration, Valid: e.duration > 0},
+				PostID:    postID,
+			})
+		if err != nil {
+			return fmt.Errorf("Creating enclosure: %w", err)
+		}
+	}
+
+	err := s.database.DeleteStaleEnclosures(context.Background(),
+		database.DeleteStaleEnclosuresParams{PostID: postID, Urls: urls})
+	if err != nil {
+		return fmt.Errorf("Removing old enclosures: %w", err)
+	}
+
+	return nil
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%v B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func formatDuration(seconds int32) string {
+	h := seconds / 3600
+	m := seconds / 60 % 60
+	sec := seconds % 60
+	if h > 0 {
+		return fmt.Sprintf("%v:%02v:%02v", h, m, sec)
+	}
+	return fmt.Sprintf("%v:%02v", m, sec)
+}
+
+func formatEnclosure(e database.Enclosure) string {
+	details := []string{}
+	if e.MimeType.Valid {
+		details = append(details, e.MimeType.String)
+	}
+	if e.Length.Valid {
+		details = append(details, formatBytes(e.Length.Int64))
+	}
+	if e.Duration.Valid {
+		details = append(details, formatDuration(e.Duration.Int32))
+	}
+	if len(details) == 0 {
+		return e.Url
+	}
+	return fmt.Sprintf("%v (%v)", e.Url, strings.Join(details, ", "))
+}
+
+// An enclosure as printed by --output, with zero for anything not known
+type enclosureRecord struct {
+	URL      string `json:"url"`
+	Type     string `json:"type"`
+	Length   int64  `json:"length"`
+	Duration int32  `json:"duration"`
+}
+
+func newEnclosureRecord(e database.Enclosure) enclosureRecord {
+	return enclosureRecord{
+		URL:      e.Url,
+		Type:     e.MimeType.String,
+		Length:   e.Length.Int64,
+		Duration: e.Duration.Int32,
+	}
+}
+
+func (e enclosureRecord) String() string {
+	return formatEnclosure(database.Enclosure{
+		Url:      e.URL,
+		MimeType: sql.NullString{String: e.Type, Valid: len(e.Type) > 0},
+		Length:   sql.NullInt64{Int64: e.Length, Valid: e.Length > 0},
+		Duration: sql.NullInt32{Int32: e.Duration, Valid: e.Duration > 0},
+	})
+}
+
+// Look up a post by its ID, or failing that by its URL
+func getPostByIDOrURL(s *state, post string) (database.Post, error) {
+	id, err := uuid.Parse(post)
+	if err == nil {
+		return s.database.GetPost(context.Background(), id)
+	}
+
+	result, err := s.database.GetPostByURL(context.Background(), post)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.Post{}, fmt.Errorf("No post with ID or URL '%v'", post)
+	}
+	return result, err
+}
+
+func enclosureFilename(enclosureURL string) string {
+	name := ""
+	u, err := url.Parse(enclosureURL)
+	if err == nil {
+		name = path.Base(u.Path)
+	}
+	if name == "" || name == "." || name == ".." || name == "/" {
+		name = "enclosure"
+	}
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r < ' ' {
+			return '_'
+		}
+		return r
+	}, name)
+}
+
+// The file names for a post's enclosures. When two enclosures have the same
+// name, the later ones get a short hash of their URL added so each is
+// downloaded to its own file.
+func enclosureFilenames(urls []string) []string {
+	names := make([]string, len(urls))
+	used := map[string]bool{}
+	for i, enclosureURL := range urls {
+		name := enclosureFilename(enclosureURL)
+		if used[name] {
+			sum := sha256.Sum256([]byte(enclosureURL))
+			ext := path.Ext(name)
+			name = fmt.Sprintf("%v-%x%v", strings.TrimSuffix(name, ext), sum[:4], ext)
+		}
+		used[name] = true
+		names[i] = name
+	}
+	return names
+}
+
+// The first byte in a Content-Range header such as "bytes 100-199/200"
+func contentRangeStart(header string) (int64, bool) {
+	rangeSpec, found := strings.CutPrefix(strings.TrimSpace(header), "bytes ")
+	if !found {
+		return 0, false
+	}
+	start, _, found := strings.Cut(rangeSpec, "-")
+	if !found {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(start), 10, 64)
+	return n, err == nil
+}
+
+// A validator for If-Range, so that a download is only resumed if the file
+// has not changed since it was started. Weak ETags are not allowed.
+func rangeValidator(res *http.Response) string {
+	etag := res.Header.Get("ETag")
+	if len(etag) > 0 && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return res.Header.Get("Last-Modified")
+}
+
+// Download an enclosure into dir as name, resuming from a previous partial download
+// if one exists. Each post's enclosures go in a directory named after the
+// post's ID, so that files with the same name in different posts do not
+// clash. Returns the path of the downloaded file.
+func downloadEnclosure(ctx context.Context, f *fetcher, enclosureURL string, dir string, name string, postID uuid.UUID) (string, error) {
+	dir = filepath.Join(dir, postID.String())
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return "", err
+	}
+
+	dest := filepath.Join(dir, name)
+	_, err = os.Stat(dest)
+	if err == nil {
+		fmt.Printf("Already downloaded %v\n", dest)
+		return dest, nil
+	}
+
+	// The validator of the response the partial file came from is kept
+	// alongside it
+	partial := dest + ".part"
+	validatorFile := partial + ".validator"
+	offset := int64(0)
+	info, err := os.Stat(partial)
+	if err == nil {
+		offset = info.Size()
+	}
+
+	req, err := f.newRequest(ctx, enclosureURL)
+	if err != nil {
+		return "", err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%v-", offset))
+		validator, err := os.ReadFile(validatorFile)
+		if err == nil && len(validator) > 0 {
+			req.Header.Set("If-Range", string(validator))
+		}
+	}
+
+	res, err := f.downloadClient(enclosureURL).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch {
+	case res.StatusCode == http.StatusPartialContent:
+		start, ok := contentRangeStart(res.Header.Get("Content-Range"))
+		if !ok || start != offset {
+			// Appending would leave a gap or repeat part of the file
+			fmt.Printf("Server did not resume %v where it left off, starting again\n", dest)
+			res.Body.Close()
+			os.Remove(validatorFile)
+			err = os.Remove(partial)
+			if err != nil {
+				return "", err
+			}
+			return downloadEnclosure(ctx, f, enclosureURL, filepath.Dir(dir), name, postID)
+		}
+		fmt.Printf("Resuming %v from %v\n", dest, formatBytes(offset))
+		flags |= os.O_APPEND
+	case res.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		// The partial file already has everything
+		os.Remove(validatorFile)
+		return dest, os.Rename(partial, dest)
+	case res.StatusCode >= 300:
+		return "", fmt.Errorf("Unexpected status code: %v", res.StatusCode)
+	default:
+		flags |= os.O_TRUNC
+		offset = 0
+		err = os.WriteFile(validatorFile, []byte(rangeValidator(res)), 0666)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	file, err := os.OpenFile(partial, flags, 0666)
+	if err != nil {
+		return "", err
+	}
+
+	n, err := io.Copy(file, res.Body)
+	closeErr := file.Close()
+	if err != nil {
+		return "", fmt.Errorf("Downloading %v: %w", enclosureURL, err)
+	}
+	if closeErr != nil {
+		return "", closeErr
+	}
+
+	err = os.Rename(partial, dest)
+	if err != nil {
+		return "", err
+	}
+	os.Remove(validatorFile)
+
+	fmt.Printf("Downloaded %v to %v\n", formatBytes(offset+n), dest)
+
+	return dest, nil
+}
+
+func handlerDownload(s *state, cmd command) error {
+	post, err := getPostByIDOrURL(s, cmd.args[0])
+	if err != nil {
+		return err
+	}
+
+	enclosures, err := s.database.GetEnclosuresForPost(context.Background(), post.ID)
+	if err != nil {
+		return err
+	}
+	if len(enclosures) == 0 {
+		return fmt.Errorf(`Post "%v" has no enclosures`, post.Title.String)
+	}
+
+	dir := s.config.Download_dir
+	if len(dir) == 0 {
+		dir = "."
+	}
+
+	urls := []string{}
+	for _, e := range enclosures {
+		urls = append(urls, e.Url)
+	}
+	names := enclosureFilenames(urls)
+	for i, e := range enclosures {
+		_, err := downloadEnclosure(context.Background(), s.fetcher, e.Url, dir, names[i], post.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestEnclosureFilenames(t *testing.T) {
+	tests := []struct {
+		name string
+		urls []string
+		want []string
+	}{
+		{"distinct", []string{"https://example.com/a.mp3", "https://example.com/b.mp3"},
+			[]string{"a.mp3", "b.mp3"}},
+		{"same name", []string{"https://example.com/hi/episode.mp3", "https://example.com/lo/episode.mp3"},
+			[]string{"episode.mp3", "episode-6d4acfaf.mp3"}},
+		{"no name", []string{"https://example.com/", "https://example.com/?id=2"},
+			[]string{"enclosure", "enclosure-f018ea57"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := enclosureFilenames(test.urls); !slices.Equal(got, test.want) {
+				t.Errorf("enclosureFilenames(%q) = %q, expected %q", test.urls, got, test.want)
+			}
+		})
+	}
+}
+
+func TestContentRangeStart(t *testing.T) {
+	tests := []struct {
+		header string
+		start  int64
+		ok     bool
+	}{
+		{"bytes 100-199/200", 100, true},
+		{"bytes 0-99/*", 0, true},
+		{" bytes 5-9/10 ", 5, true},
+		{"bytes */200", 0, false},
+		{"items 100-199/200", 0, false},
+		{"bytes x-199/200", 0, false},
+		{"", 0, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.header, func(t *testing.T) {
+			start, ok := contentRangeStart(test.header)
+			if start != test.start || ok != test.ok {
+				t.Errorf("contentRangeStart(%q) = %v, %v, expected %v, %v",
+					test.header, start, ok, test.start, test.ok)
+			}
+		})
+	}
+}
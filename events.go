@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Tavis7/bootdev-gator/internal/database"
+)
+
+func logFeedEvent(queries *database.Queries, logger *slog.Logger, feedID uuid.UUID, kind, detail string) error {
+	now := time.Now().UTC()
+	_, err := queries.CreateFeedEvent(context.Background(),
+		database.CreateFeedEventParams{
+			ID:        uuid.New(),
+			CreatedAt: now,
+			UpdatedAt: now,
+			Kind:      kind,
+			Detail:    detail,
+			FeedID:    feedID,
+		})
+	if err != nil {
+		return fmt.Errorf("Logging feed event: %w", err)
+	}
+
+	logger.Info("Feed event", "kind", kind, "detail", detail)
+
+	return nil
+}
+
+// Point a feed at the URL it has permanently moved to. If another feed already
+// uses that URL, the follows and posts of this feed are merged into it and
+// this feed is deleted. Returns the ID of the feed that now has the new URL.
+func moveFeed(s *state, logger *slog.Logger, feed database.Feed, newURL string) (uuid.UUID, error) {
+	existing, err := s.database.GetFeedByURL(context.Background(), newURL)
+	if errors.Is(err, sql.ErrNoRows) {
+		_, err = s.database.UpdateFeedURL(context.Background(),
+			database.UpdateFeedURLParams{
+				Url:       newURL,
+				UpdatedAt: time.Now().UTC(),
+				ID:        feed.ID,
+			})
+		if err != nil {
+			return uuid.UUID{}, fmt.Errorf("Updating feed URL: %w", err)
+		}
+
+		err = logFeedEvent(s.database, logger, feed.ID, "moved",
+			fmt.Sprintf("Moved from %v to %v", feed.Url, newURL))
+		return feed.ID, err
+	}
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+
+	tx, err := s.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+	defer tx.Rollback()
+	queries := queriesInTx(tx)
+
+	err = queries.MoveFeedFollows(context.Background(),
+		database.MoveFeedFollowsParams{
+			NewFeedID: existing.ID,
+			OldFeedID: feed.ID,
+		})
+	if err != nil {
+		return uuid.UUID{}, fmt.Errorf("Moving feed follows: %w", err)
+	}
+
+	err = queries.MovePosts(context.Background(),
+		database.MovePostsParams{
+			NewFeedID: existing.ID,
+			OldFeedID: feed.ID,
+		})
+	if err != nil {
+		return uuid.UUID{}, fmt.Errorf("Moving posts: %w", err)
+	}
+
+	// Keep the moved feed's history and credentials, which deleting it would
+	// otherwise take with it. The credentials of the feed it is merged into
+	// win.
+	err = queries.MoveFeedEvents(context.Background(),
+		database.MoveFeedEventsParams{
+			NewFeedID: existing.ID,
+			OldFeedID: feed.ID,
+		})
+	if err != nil {
+		return uuid.UUID{}, fmt.Errorf("Moving feed events: %w", err)
+	}
+
+	err = queries.MoveFeedCredentials(context.Background(),
+		database.MoveFeedCredentialsParams{
+			NewFeedID: existing.ID,
+			OldFeedID: feed.ID,
+		})
+	if err != nil {
+		return uuid.UUID{}, fmt.Errorf("Moving feed credentials: %w", err)
+	}
+
+	err = queries.MoveFeedFetches(context.Background(),
+		database.MoveFeedFetchesParams{
+			NewFeedID: existing.ID,
+			OldFeedID: feed.ID,
+		})
+	if err != nil {
+		return uuid.UUID{}, fmt.Errorf("Moving feed fetches: %w", err)
+	}
+
+	err = queries.DeleteFeed(context.Background(), feed.ID)
+	if err != nil {
+		return uuid.UUID{}, fmt.Errorf("Deleting moved feed: %w", err)
+	}
+
+	err = logFeedEvent(queries, logger, existing.ID, "merged",
+		fmt.Sprintf(`"%v" moved from %v to %v and was merged into "%v"`,
+			feed.Name, feed.Url, newURL, existing.Name))
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+
+	return existing.ID, tx.Commit()
+}
+
+func handlerEvents(s *state, cmd command) error {
+	feed, err := s.database.GetFeedByURL(context.Background(), cmd.args[0])
+	if err != nil {
+		return err
+	}
+
+	events, err := s.database.GetFeedEvents(context.Background(), feed.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, event := range events {
+		fmt.Printf("[%v] %v: %v\n", event.CreatedAt, event.Kind, event.Detail)
+	}
+
+	return nil
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Tavis7/bootdev-gator/internal/config"
+)
+
+// Fetches feeds and pages over HTTP with the settings from the config file
+type fetcher struct {
+	transport *http.Transport
+	// Only used for feeds that have opted out of certificate verification
+	insecureTransport *http.Transport
+	insecureFeeds     []string
+	timeout           time.Duration
+	maxBodySize       int64
+	userAgent         string
+}
+
+type fetchResult struct {
+	response *http.Response
+	body     []byte
+	// The URL the content was eventually fetched from
+	finalURL string
+	// Set if the URL permanently redirected (301 or 308) somewhere else
+	movedTo string
+	// How the body was compressed, and how big it was before decompressing
+	contentEncoding  string
+	bytesTransferred int64
+}
+
+func newFetcher(conf *config.Config) (*fetcher, error) {
+	timeout, err := conf.FetchTimeout()
+	if err != nil {
+		return nil, err
+	}
+
+	userAgent := "gator"
+	if len(conf.Fetch_user_agent) > 0 {
+		userAgent = conf.Fetch_user_agent
+	}
+	if len(conf.Fetch_contact_url) > 0 {
+		userAgent += " (+" + conf.Fetch_contact_url + ")"
+	}
+
+	proxy := http.ProxyFromEnvironment
+	if len(conf.Fetch_proxy) > 0 {
+		// http.Transport handles http, https and socks5 proxies
+		proxyURL, err := url.Parse(conf.Fetch_proxy)
+		if err != nil {
+			return nil, fmt.Errorf("fetch_proxy: %w", err)
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+
+	tlsConfig := &tls.Config{}
+	if len(conf.Fetch_ca_bundle) > 0 {
+		pem, err := os.ReadFile(conf.Fetch_ca_bundle)
+		if err != nil {
+			return nil, fmt.Errorf("fetch_ca_bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("fetch_ca_bundle: No certificates found in %v",
+				conf.Fetch_ca_bundle)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxy
+	transport.TLSClientConfig = tlsConfig
+
+	insecureTransport := transport.Clone()
+	insecureTransport.TLSClientConfig = tlsConfig.Clone()
+	insecureTransport.TLSClientConfig.InsecureSkipVerify = true
+
+	return &fetcher{
+		transport:         transport,
+		insecureTransport: insecureTransport,
+		insecureFeeds:     conf.Fetch_insecure_feeds,
+		timeout:           timeout,
+		maxBodySize:       conf.MaxBodySize(),
+		userAgent:         userAgent,
+	}, nil
+}
+
+// Entries in fetch_insecure_feeds are either feed URLs or host names
+func (f *fetcher) transportFor(rawURL string) *http.Transport {
+	host := ""
+	u, err := url.Parse(rawURL)
+	if err == nil {
+		host = u.Hostname()
+	}
+	for _, insecure := range f.insecureFeeds {
+		if insecure == rawURL || strings.EqualFold(insecure, host) {
+			return f.insecureTransport
+		}
+	}
+	return f.transport
+}
+
+func (f *fetcher) newRequest(ctx context.Context, rawURL string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", f.userAgent)
+	return req, nil
+}
+
+func sameOrigin(a, b *url.URL) bool {
+	return strings.EqualFold(a.Scheme, b.Scheme) && strings.EqualFold(a.Host, b.Host)
+}
+
+// Fetch a URL, following redirects and keeping track of where it has
+// permanently moved to. auth may be nil, otherwise it is only sent to the
+// URL's own origin, and the URL is not considered moved to another one.
+func (f *fetcher) fetch(ctx context.Context, pageURL string, auth feedAuth) (fetchResult, error) {
+	result := fetchResult{finalURL: pageURL}
+
+	req, err := f.newRequest(ctx, pageURL)
+	if err != nil {
+		return result, err
+	}
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	auth.apply(req)
+
+	permanent := true
+	client := &http.Client{
+		Transport: f.transportFor(pageURL),
+		Timeout:   f.timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return fmt.Errorf("Stopped after %v redirects", len(via))
+			}
+			crossOrigin := !sameOrigin(req.URL, via[0].URL)
+			if crossOrigin {
+				auth.remove(req)
+			}
+			// Only a chain of permanent redirects means the URL has moved,
+			// and feeds with credentials do not move to another site
+			status := req.Response.StatusCode
+			if permanent && (status == http.StatusMovedPermanently ||
+				status == http.StatusPermanentRedirect) &&
+				!(crossOrigin && len(auth) > 0) {
+				result.movedTo = req.URL.String()
+			} else {
+				permanent = false
+			}
+			return nil
+		},
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return result, err
+	}
+	defer res.Body.Close()
+
+	result.response = res
+	result.finalURL = res.Request.URL.String()
+
+	if res.StatusCode >= 300 {
+		return result, fmt.Errorf("Unexpected status code: %v", res.StatusCode)
+	}
+
+	counter := &countingReader{reader: res.Body}
+	body, encoding, err := decompressBody(counter, res.Header.Get("Content-Encoding"))
+	result.contentEncoding = encoding
+	if err != nil {
+		result.bytesTransferred = counter.n
+		return result, err
+	}
+
+	// Read one byte more than allowed to tell if the body was too big. The
+	// limit applies after decompressing, so a small compressed response
+	// cannot blow up into something enormous.
+	result.body, err = io.ReadAll(io.LimitReader(body, f.maxBodySize+1))
+	result.bytesTransferred = counter.n
+	if err != nil {
+		return result, err
+	}
+	if int64(len(result.body)) > f.maxBodySize {
+		result.body = nil
+		return result, fmt.Errorf("Response is larger than %v", formatBytes(f.maxBodySize))
+	}
+
+	return result, nil
+}
+
+// Returned by fetchFeed, wrapped, when the feed was fetched but could not be
+// parsed
+var errParseFeed = errors.New("Parsing feed")
+
+func (f *fetcher) fetchFeed(ctx context.Context, feedURL string, auth feedAuth) (RSSFeed, fetchResult, error) {
+	result, err := f.fetch(ctx, feedURL, auth)
+	if err != nil {
+		return RSSFeed{}, result, err
+	}
+
+	feed, err := parseFeed(result.body, result.response.Header.Get("Content-Type"))
+	if err != nil {
+		return feed, result, fmt.Errorf("%w: %w", errParseFeed, err)
+	}
+	return feed, result, nil
+}
+
+// A client for downloads, which have no size limit or overall timeout
+func (f *fetcher) downloadClient(rawURL string) *http.Client {
+	return &http.Client{Transport: f.transportFor(rawURL)}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+type flagKind int
+
+const (
+	flagBool flagKind = iota
+	flagString
+	flagInt
+)
+
+// A --flag accepted by a command, or by every command
+type flagDef struct {
+	name string
+	kind flagKind
+	// Shown in usage, as in --limit <n>
+	valueName    string
+	defaultValue any
+	doc          string
+}
+
+func boolFlag(name, doc string) flagDef {
+	return flagDef{name: name, kind: flagBool, defaultValue: false, doc: doc}
+}
+
+func stringFlag(name, valueName, defaultValue, doc string) flagDef {
+	return flagDef{name: name, kind: flagString, valueName: valueName,
+		defaultValue: defaultValue, doc: doc}
+}
+
+func intFlag(name, valueName string, defaultValue int, doc string) flagDef {
+	return flagDef{name: name, kind: flagInt, valueName: valueName,
+		defaultValue: defaultValue, doc: doc}
+}
+
+func (f flagDef) usage() string {
+	if f.kind == flagBool {
+		return "--" + f.name
+	}
+	return fmt.Sprintf("--%v <%v>", f.name, f.valueName)
+}
+
+func (f flagDef) parse(value string) (any, error) {
+	switch f.kind {
+	case flagBool:
+		return strconv.ParseBool(value)
+	case flagInt:
+		return strconv.Atoi(value)
+	}
+	return value, nil
+}
+
+// Parse the flags in args that are in defs, returning their values and the
+// remaining arguments. Unknown flags are an error unless keepUnknown is set,
+// in which case they are left with the remaining arguments. Flags can be
+// given anywhere up to a "--".
+func parseFlags(defs []flagDef, args []string, keepUnknown bool) (map[string]any, []string, error) {
+	values := map[string]any{}
+	for _, def := range defs {
+		values[def.name] = def.defaultValue
+	}
+
+	rest := []string{}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			// Leave the "--" for the command's own flags to stop at
+			if keepUnknown {
+				rest = append(rest, arg)
+			}
+			rest = append(rest, args[i+1:]...)
+			break
+		}
+		if !strings.HasPrefix(arg, "--") {
+			rest = append(rest, arg)
+			continue
+		}
+
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+		def, found := findFlag(defs, name)
+		if !found {
+			if keepUnknown {
+				rest = append(rest, arg)
+				continue
+			}
+			return nil, nil, fmt.Errorf("Unknown option '--%v'", name)
+		}
+
+		if !hasValue {
+			if def.kind == flagBool {
+				value = "true"
+			} else {
+				i++
+				if i >= len(args) {
+					return nil, nil, fmt.Errorf("%v expects a value", def.usage())
+				}
+				value = args[i]
+			}
+		}
+		parsed, err := def.parse(value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Invalid value for --%v: '%v'", name, value)
+		}
+		values[name] = parsed
+	}
+
+	return values, rest, nil
+}
+
+func findFlag(defs []flagDef, name string) (flagDef, bool) {
+	for _, def := range defs {
+		if def.name == name {
+			return def, true
+		}
+	}
+	return flagDef{}, false
+}
+
+// Work out how many arguments a usage string such as "<url> [<kind>
+// <credentials>...]" allows. max is -1 if there is no limit.
+func argCounts(usage string) (int, int) {
+	minArgs, maxArgs := 0, 0
+	depth := 0
+	for _, token := range strings.Fields(usage) {
+		opening := len(token) - len(strings.TrimLeft(token, "["))
+		closing := len(token) - len(strings.TrimRight(token, "]"))
+		depth += opening
+		if strings.Contains(token, "<") {
+			if depth == 0 {
+				minArgs++
+			}
+			if maxArgs >= 0 {
+				maxArgs++
+			}
+			if strings.Contains(token, "...") {
+				maxArgs = -1
+			}
+		}
+		depth -= closing
+	}
+	return minArgs, maxArgs
+}
+
+func checkArgCount(usage string, args []string) error {
+	minArgs, maxArgs := argCounts(usage)
+	switch {
+	case minArgs == maxArgs && len(args) != minArgs:
+		if minArgs == 0 {
+			return fmt.Errorf("No arguments expected")
+		}
+		return fmt.Errorf("Expected %v argument(s): %v", minArgs, usage)
+	case len(args) < minArgs:
+		return fmt.Errorf("Expected at least %v argument(s): %v", minArgs, usage)
+	case maxArgs >= 0 && len(args) > maxArgs:
+		return fmt.Errorf("Expected at most %v argument(s): %v", maxArgs, usage)
+	}
+	return nil
+}
+
+// Print a table of flags and what they do
+func writeFlagDocs(w io.Writer, defs []flagDef) error {
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, def := range defs {
+		doc := def.doc
+		if def.kind != flagBool && def.defaultValue != nil &&
+			fmt.Sprint(def.defaultValue) != "" && fmt.Sprint(def.defaultValue) != "0" {
+			doc += fmt.Sprintf(" (default %v)", def.defaultValue)
+		}
+		fmt.Fprintf(table, "    %v\t%v\n", def.usage(), doc)
+	}
+	return table.Flush()
+}
+
+func (c command) boolFlag(name string) bool {
+	value, _ := c.flags[name].(bool)
+	return value
+}
+
+func (c command) stringFlag(name string) string {
+	value, _ := c.flags[name].(string)
+	return value
+}
+
+func (c command) intFlag(name string) int {
+	value, _ := c.flags[name].(int)
+	return value
+}
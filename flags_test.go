@@ -0,0 +1,127 @@
+package main
+
+import (
+	"maps"
+	"slices"
+	"testing"
+)
+
+func TestParseFlags(t *testing.T) {
+	defs := []flagDef{
+		boolFlag("all", ""),
+		stringFlag("output", "format", "text", ""),
+		intFlag("limit", "n", 2, ""),
+	}
+	defaults := map[string]any{"all": false, "output": "text", "limit": 2}
+
+	tests := []struct {
+		name        string
+		args        []string
+		keepUnknown bool
+		values      map[string]any
+		rest        []string
+		err         string
+	}{
+		{"none", []string{"a", "b"}, false, defaults, []string{"a", "b"}, ""},
+		{"bool", []string{"--all", "a"}, false,
+			map[string]any{"all": true, "output": "text", "limit": 2}, []string{"a"}, ""},
+		{"bool value", []string{"--all=false"}, false, defaults, []string{}, ""},
+		{"separate value", []string{"a", "--limit", "5"}, false,
+			map[string]any{"all": false, "output": "text", "limit": 5}, []string{"a"}, ""},
+		{"equals value", []string{"--output=csv", "a"}, false,
+			map[string]any{"all": false, "output": "csv", "limit": 2}, []string{"a"}, ""},
+		{"empty value", []string{"--output="}, false,
+			map[string]any{"all": false, "output": "", "limit": 2}, []string{}, ""},
+		{"after --", []string{"a", "--", "--all"}, false, defaults, []string{"a", "--all"}, ""},
+		{"kept --", []string{"--", "--all"}, true, defaults, []string{"--", "--all"}, ""},
+		{"single dash", []string{"-5"}, false, defaults, []string{"-5"}, ""},
+		{"unknown", []string{"--bogus"}, false, nil, nil, "Unknown option '--bogus'"},
+		{"kept unknown", []string{"--bogus", "--all"}, true,
+			map[string]any{"all": true, "output": "text", "limit": 2}, []string{"--bogus"}, ""},
+		{"missing value", []string{"--limit"}, false, nil, nil, "--limit <n> expects a value"},
+		{"bad int", []string{"--limit", "lots"}, false, nil, nil,
+			"Invalid value for --limit: 'lots'"},
+		{"bad bool", []string{"--all=maybe"}, false, nil, nil,
+			"Invalid value for --all: 'maybe'"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			values, rest, err := parseFlags(defs, test.args, test.keepUnknown)
+			if len(test.err) > 0 {
+				if err == nil || err.Error() != test.err {
+					t.Errorf("Error is %v, expected %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseFlags: %v", err)
+			}
+			if !maps.Equal(values, test.values) {
+				t.Errorf("Values are %v, expected %v", values, test.values)
+			}
+			if !slices.Equal(rest, test.rest) {
+				t.Errorf("Remaining arguments are %q, expected %q", rest, test.rest)
+			}
+		})
+	}
+}
+
+func TestArgCounts(t *testing.T) {
+	tests := []struct {
+		usage   string
+		minArgs int
+		maxArgs int
+	}{
+		{"", 0, 0},
+		{"<url>", 1, 1},
+		{"<name> <url>", 2, 2},
+		{"[<n>]", 0, 1},
+		{"<url> [<kind> <credentials>...]", 1, -1},
+		{"<post> [<from> [<to>]]", 1, 3},
+		{"<args>...", 1, -1},
+		{"[--all]", 0, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.usage, func(t *testing.T) {
+			minArgs, maxArgs := argCounts(test.usage)
+			if minArgs != test.minArgs || maxArgs != test.maxArgs {
+				t.Errorf("argCounts(%q) = %v, %v, expected %v, %v",
+					test.usage, minArgs, maxArgs, test.minArgs, test.maxArgs)
+			}
+		})
+	}
+}
+
+func TestCheckArgCount(t *testing.T) {
+	tests := []struct {
+		name  string
+		usage string
+		args  []string
+		err   string
+	}{
+		{"none", "", nil, ""},
+		{"unexpected", "", []string{"a"}, "No arguments expected"},
+		{"exact", "<url>", []string{"a"}, ""},
+		{"too few", "<name> <url>", []string{"a"}, "Expected 2 argument(s): <name> <url>"},
+		{"optional", "<url> [<n>]", []string{"a"}, ""},
+		{"below minimum", "<url> [<n>]", nil, "Expected at least 1 argument(s): <url> [<n>]"},
+		{"above maximum", "<url> [<n>]", []string{"a", "b", "c"},
+			"Expected at most 2 argument(s): <url> [<n>]"},
+		{"unlimited", "<url> [<more>...]", []string{"a", "b", "c", "d"}, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := checkArgCount(test.usage, test.args)
+			message := ""
+			if err != nil {
+				message = err.Error()
+			}
+			if message != test.err {
+				t.Errorf("Error is %q, expected %q", message, test.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Tavis7/bootdev-gator/internal/database"
+)
+
+// What `fetch` found out about a feed
+type fetchReport struct {
+	URL              string           `json:"url"`
+	FinalURL         string           `json:"final_url"`
+	MovedTo          string           `json:"moved_to,omitempty"`
+	Status           int              `json:"status,omitempty"`
+	Headers          http.Header      `json:"headers,omitempty"`
+	ContentEncoding  string           `json:"content_encoding,omitempty"`
+	BytesTransferred int64            `json:"bytes_transferred"`
+	BodySize         int              `json:"body_size"`
+	Format           string           `json:"format,omitempty"`
+	Warnings         []string         `json:"warnings"`
+	Error            string           `json:"error,omitempty"`
+	Feed             *fetchReportFeed `json:"feed,omitempty"`
+}
+
+type fetchReportFeed struct {
+	Title       string            `json:"title"`
+	Link        string            `json:"link"`
+	Description string            `json:"description"`
+	Language    string            `json:"language,omitempty"`
+	Image       string            `json:"image,omitempty"`
+	Items       []fetchReportItem `json:"items"`
+}
+
+type fetchReportItem struct {
+	GUID        string                 `json:"guid"`
+	Title       string                 `json:"title"`
+	Link        string                 `json:"link"`
+	Published   string                 `json:"published,omitempty"`
+	Description string                 `json:"description"`
+	Enclosures  []fetchReportEnclosure `json:"enclosures,omitempty"`
+}
+
+type fetchReportEnclosure struct {
+	URL      string `json:"url"`
+	Type     string `json:"type,omitempty"`
+	Length   int64  `json:"length,omitempty"`
+	Duration int32  `json:"duration,omitempty"`
+}
+
+// Normalise a parsed feed the same way scrapeFeeds would before saving it
+func newFetchReportFeed(feed RSSFeed) (*fetchReportFeed, []string) {
+	warnings := []string{}
+	channel := feed.Channel
+	result := &fetchReportFeed{
+		Title:       channel.Title,
+		Link:        channel.Link,
+		Description: channel.Description,
+		Language:    channel.Language,
+		Image:       feed.imageURL(),
+		Items:       []fetchReportItem{},
+	}
+
+	for _, item := range channel.Item {
+		reportItem := fetchReportItem{
+			GUID:        itemGUID(item),
+			Title:       item.Title,
+			Link:        item.Link,
+			Description: item.Description,
+		}
+		published, err := parseDate(item.PubDate)
+		if err != nil {
+			warnings = append(warnings,
+				fmt.Sprintf(`Item "%v" has an unreadable date: %v`, item.Title, err))
+		} else {
+			reportItem.Published = published.Format(time.RFC3339)
+		}
+		for _, e := range itemEnclosures(item) {
+			reportItem.Enclosures = append(reportItem.Enclosures, fetchReportEnclosure{
+				URL:      e.url,
+				Type:     e.mimeType,
+				Length:   e.length,
+				Duration: e.duration,
+			})
+		}
+		result.Items = append(result.Items, reportItem)
+	}
+
+	return result, warnings
+}
+
+func printFetchHeaders(report fetchReport) {
+	fmt.Printf("%v %v\n", report.Status, http.StatusText(report.Status))
+	if report.FinalURL != report.URL {
+		fmt.Printf("Fetched from: %v\n", report.FinalURL)
+	}
+	if len(report.MovedTo) > 0 {
+		fmt.Printf("Permanently moved to: %v\n", report.MovedTo)
+	}
+	names := []string{}
+	for name := range report.Headers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		for _, value := range report.Headers[name] {
+			fmt.Printf("%v: %v\n", name, value)
+		}
+	}
+	fmt.Printf("\n")
+}
+
+func printFetchReport(report fetchReport) {
+	printFetchHeaders(report)
+
+	transferred := formatBytes(report.BytesTransferred)
+	if len(report.ContentEncoding) > 0 {
+		transferred += " " + report.ContentEncoding
+	}
+	fmt.Printf("Transferred: %v, %v decompressed\n", transferred, formatBytes(int64(report.BodySize)))
+	if len(report.Format) > 0 {
+		fmt.Printf("Format: %v\n", report.Format)
+	}
+	for _, warning := range report.Warnings {
+		fmt.Printf("Warning: %v\n", warning)
+	}
+	if report.Feed == nil {
+		return
+	}
+
+	feed := report.Feed
+	fmt.Printf("\nTitle: %v\nLink: %v\n", feed.Title, feed.Link)
+	if len(feed.Language) > 0 {
+		fmt.Printf("Language: %v\n", feed.Language)
+	}
+	if len(feed.Image) > 0 {
+		fmt.Printf("Image: %v\n", feed.Image)
+	}
+	if len(feed.Description) > 0 {
+		fmt.Printf("%v\n", strings.Join(wrapText(feed.Description, browseTextWidth, ""), "\n"))
+	}
+	fmt.Printf("%v items\n\n", len(feed.Items))
+
+	for _, item := range feed.Items {
+		published := item.Published
+		if len(published) == 0 {
+			published = "no date"
+		}
+		fmt.Printf(`[%v] "%v"`+"\n    link: %v\n    guid: %v\n",
+			published, item.Title, item.Link, item.GUID)
+		for _, e := range item.Enclosures {
+			fmt.Printf("    enclosure: %v\n", enclosureRecord(e))
+		}
+		if len(item.Description) > 0 {
+			fmt.Printf("\n%v\n\n", renderPlainText(item.Description, browseTextWidth, "    "))
+		}
+	}
+}
+
+// Fetch and parse a feed without saving anything, to see what gator makes
+// of it
+func handlerFetch(s *state, cmd command) error {
+	mode := "parsed"
+	given := 0
+	for _, m := range []string{"raw", "parsed", "json"} {
+		if cmd.boolFlag(m) {
+			mode = m
+			given++
+		}
+	}
+	if given > 1 {
+		return fmt.Errorf("Only one of --raw, --parsed and --json can be given")
+	}
+
+	// Credentials in the URL are used, but nothing is looked up in the
+	// database
+	feedURL, user := splitURLCredentials(cmd.args[0])
+	auth := feedAuth{}
+	if user != nil {
+		password, _ := user.Password()
+		auth = append(auth, database.FeedCredential{
+			Kind:  "basic",
+			Value: user.Username() + ":" + password,
+		})
+	}
+
+	result, fetchErr := s.fetcher.fetch(context.Background(), feedURL, auth)
+	report := fetchReport{
+		URL:              feedURL,
+		FinalURL:         result.finalURL,
+		MovedTo:          result.movedTo,
+		ContentEncoding:  result.contentEncoding,
+		BytesTransferred: result.bytesTransferred,
+		BodySize:         len(result.body),
+		Warnings:         []string{},
+	}
+	if result.response != nil {
+		report.Status = result.response.StatusCode
+		report.Headers = result.response.Header
+	}
+
+	err := fetchErr
+	if err == nil && mode != "raw" {
+		feed, parseErr := parseFeed(result.body, result.response.Header.Get("Content-Type"))
+		err = parseErr
+		if err == nil {
+			report.Format = feed.format
+			report.Warnings = append(report.Warnings, feed.warnings...)
+			reportFeed, warnings := newFetchReportFeed(feed)
+			report.Feed = reportFeed
+			report.Warnings = append(report.Warnings, warnings...)
+		}
+	}
+	if err != nil {
+		report.Error = err.Error()
+	}
+
+	switch mode {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "    ")
+		encodeErr := encoder.Encode(report)
+		if encodeErr != nil {
+			return encodeErr
+		}
+	case "raw":
+		if result.response != nil {
+			printFetchHeaders(report)
+			os.Stdout.Write(result.body)
+		}
+	default:
+		if result.response != nil {
+			printFetchReport(report)
+		}
+	}
+
+	return err
+}
@@ -1,57 +1,450 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultMinPollInterval = 15 * time.Minute
+	DefaultMaxPollInterval = 24 * time.Hour
+	DefaultFetchTimeout    = 30 * time.Second
+	DefaultMaxBodySize     = 20 * 1024 * 1024
+
+	DefaultDaemonListenAddress = "127.0.0.1:8417"
 )
 
 type Config struct {
 	Db_url            string `json:"db_url"`
 	Current_user_name string `json:"current_user_name"`
+	Download_dir      string `json:"download_dir,omitempty"`
+	Min_poll_interval string `json:"min_poll_interval,omitempty"`
+	Max_poll_interval string `json:"max_poll_interval,omitempty"`
+
+	// Settings for fetching feeds
+	Fetch_timeout        string   `json:"fetch_timeout,omitempty"`
+	Fetch_max_body_size  int64    `json:"fetch_max_body_size,omitempty"`
+	Fetch_user_agent     string   `json:"fetch_user_agent,omitempty"`
+	Fetch_contact_url    string   `json:"fetch_contact_url,omitempty"`
+	Fetch_proxy          string   `json:"fetch_proxy,omitempty"`
+	Fetch_ca_bundle      string   `json:"fetch_ca_bundle,omitempty"`
+	Fetch_insecure_feeds []string `json:"fetch_insecure_feeds,omitempty"`
+
+	// Settings for `gator daemon`
+	Daemon_pid_file       string `json:"daemon_pid_file,omitempty"`
+	Daemon_listen_address string `json:"daemon_listen_address,omitempty"`
+
+	// Where `gator shell` saves the lines typed into it
+	Shell_history_file string `json:"shell_history_file,omitempty"`
+
+	// The file the config was read from
+	path string
+}
+
+// Environment variables that change where the config is and what is in it
+const (
+	ConfigPathEnv = "GATOR_CONFIG"
+	// Followed by a setting's name in upper case, as in GATOR_DB_URL
+	EnvPrefix = "GATOR_"
+	// Short for GATOR_CURRENT_USER_NAME
+	UserEnv = "GATOR_USER"
+)
+
+// Where the config is read from unless another file is given
+func DefaultPath() (string, error) {
+	return getConfigFilePath()
 }
 
+// $GATOR_CONFIG, or otherwise $XDG_CONFIG_HOME/gator/config.json
 func getConfigFilePath() (string, error) {
-	filename := ".gatorconfig.json"
+	if path := os.Getenv(ConfigPathEnv); len(path) > 0 {
+		return path, nil
+	}
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if len(dir) == 0 {
+		home_dir, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home_dir, ".config")
+	}
+	return filepath.Join(dir, "gator", "config.json"), nil
+}
+
+// Where the config was kept before it moved to $XDG_CONFIG_HOME
+func legacyConfigFilePath() (string, error) {
 	home_dir, err := os.UserHomeDir()
 	if err != nil {
 		return "", err
 	}
-	return home_dir + "/" + filename, nil
+	return filepath.Join(home_dir, ".gatorconfig.json"), nil
 }
 
+// Move ~/.gatorconfig.json to filename, unless there is already a config
+// there. It returns the path of the file moved, if any.
+func migrateLegacyFile(filename string) (string, error) {
+	legacy, err := legacyConfigFilePath()
+	if err != nil {
+		return "", err
+	}
+	_, err = os.Stat(filename)
+	if !errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	contents, err := os.ReadFile(legacy)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return legacy, err
+	}
+
+	// Copied rather than renamed, as the two may be on different file
+	// systems
+	err = os.MkdirAll(filepath.Dir(filename), 0700)
+	if err != nil {
+		return legacy, err
+	}
+	err = os.WriteFile(filename, contents, 0600)
+	if err != nil {
+		return legacy, err
+	}
+	return legacy, os.Remove(legacy)
+}
+
+// Read the config from the default path, moving it there from
+// ~/.gatorconfig.json if need be. Environment variables are not applied.
 func Read() (Config, error) {
 	filename, err := getConfigFilePath()
 	if err != nil {
 		return Config{}, err
 	}
+	if len(os.Getenv(ConfigPathEnv)) == 0 {
+		legacy, err := migrateLegacyFile(filename)
+		if err != nil {
+			return Config{path: filename}, fmt.Errorf("moving %v to %v: %w", legacy, filename, err)
+		}
+		if len(legacy) > 0 {
+			slog.Info("Moved config file", "from", legacy, "to", filename)
+		}
+	}
+	return ReadFile(filename)
+}
 
+// Read the config from filename. If it cannot be read, the Config returned
+// is empty but remembers filename, so that it can be saved there.
+func ReadFile(filename string) (Config, error) {
+	config := Config{path: filename}
 	contents, err := os.ReadFile(filename)
 	if err != nil {
-		return Config{}, err
+		return config, err
 	}
 
-	config := Config{}
-	json.Unmarshal(contents, &config)
+	err = json.Unmarshal(contents, &config)
+	if err != nil {
+		return Config{path: filename}, fmt.Errorf("%v: %w", filename, describeJSONError(contents, err))
+	}
 	return config, nil
 }
 
-func (c *Config) SetUser(username string) error {
-	config, err := Read()
+// Add where a JSON error is, as line:column, to the error
+func describeJSONError(data []byte, err error) error {
+	offset := int64(-1)
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		offset = syntaxErr.Offset
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset
+	}
+	if offset < 0 || offset > int64(len(data)) {
+		return err
+	}
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := len(before) - bytes.LastIndexByte(before, '\n')
+	return fmt.Errorf("line %v, column %v: %w", line, column, err)
+}
+
+// The file c was read from, and is saved to
+func (c *Config) Path() (string, error) {
+	if len(c.path) > 0 {
+		return c.path, nil
+	}
+	return getConfigFilePath()
+}
+
+// Save the whole config to its file
+func (c *Config) Save() error {
+	filename, err := c.Path()
 	if err != nil {
 		return err
 	}
-	config.Current_user_name = username
-	filename, err := getConfigFilePath()
+	file_contents, err := json.MarshalIndent(c, "", "    ")
 	if err != nil {
 		return err
 	}
-	file_contents, err := json.Marshal(config)
+	err = os.MkdirAll(filepath.Dir(filename), 0700)
 	if err != nil {
 		return err
 	}
-	err = os.WriteFile(filename, file_contents, 0666)
+	return os.WriteFile(filename, append(file_contents, '\n'), 0666)
+}
+
+// Save username as the current user. Anything else that has been changed in
+// c is not saved.
+func (c *Config) SetUser(username string) error {
+	filename, err := c.Path()
+	if err != nil {
+		return err
+	}
+	// The file is created if settings were only given in the environment
+	config, err := ReadFile(filename)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	config.Current_user_name = username
+	c.Current_user_name = username
+	return config.Save()
+}
+
+// The names of the settings, as they are in the config file
+func Keys() []string {
+	keys := []string{}
+	configType := reflect.TypeFor[Config]()
+	for i := range configType.NumField() {
+		name, _, _ := strings.Cut(configType.Field(i).Tag.Get("json"), ",")
+		if len(name) > 0 {
+			keys = append(keys, name)
+		}
+	}
+	return keys
+}
+
+func (c *Config) field(key string) (reflect.Value, error) {
+	configType := reflect.TypeFor[Config]()
+	for i := range configType.NumField() {
+		name, _, _ := strings.Cut(configType.Field(i).Tag.Get("json"), ",")
+		if len(name) > 0 && name == key {
+			return reflect.ValueOf(c).Elem().Field(i), nil
+		}
+	}
+	return reflect.Value{}, fmt.Errorf("unknown setting '%v'", key)
+}
+
+// A setting as text. Lists are separated by commas.
+func (c *Config) Get(key string) (string, error) {
+	field, err := c.field(key)
+	if err != nil {
+		return "", err
+	}
+	switch value := field.Interface().(type) {
+	case []string:
+		return strings.Join(value, ","), nil
+	case int64:
+		if value == 0 {
+			return "", nil
+		}
+		return strconv.FormatInt(value, 10), nil
+	}
+	return field.String(), nil
+}
+
+// Change a setting, given as text in the same form Get returns. An empty
+// value removes the setting, so the default is used. Nothing is saved.
+func (c *Config) Set(key, value string) error {
+	field, err := c.field(key)
 	if err != nil {
 		return err
 	}
+	switch field.Interface().(type) {
+	case []string:
+		list := []string{}
+		for item := range strings.SplitSeq(value, ",") {
+			if item = strings.TrimSpace(item); len(item) > 0 {
+				list = append(list, item)
+			}
+		}
+		field.Set(reflect.ValueOf(list))
+	case int64:
+		n := int64(0)
+		if len(value) > 0 {
+			n, err = strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return fmt.Errorf("%v: expected a number: %w", key, err)
+			}
+		}
+		field.SetInt(n)
+	default:
+		field.SetString(value)
+	}
 	return nil
 }
+
+// Override settings with environment variables named after them, such as
+// GATOR_DB_URL and GATOR_FETCH_TIMEOUT, or GATOR_USER for current_user_name.
+// Variables that are set but empty are ignored.
+func (c *Config) ApplyEnv() error {
+	for _, key := range Keys() {
+		name := EnvPrefix + strings.ToUpper(key)
+		value := os.Getenv(name)
+		if key == "current_user_name" && len(os.Getenv(UserEnv)) > 0 {
+			name, value = UserEnv, os.Getenv(UserEnv)
+		}
+		if len(value) == 0 {
+			continue
+		}
+		err := c.Set(key, value)
+		if err != nil {
+			return fmt.Errorf("%v: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Check settings that can be checked without using them. Problems with
+// settings such as fetch_proxy only show up when they are used.
+func (c *Config) Check() []error {
+	problems := []error{}
+	if len(c.Db_url) == 0 {
+		problems = append(problems, fmt.Errorf("db_url is not set"))
+	}
+	_, _, err := c.PollIntervals()
+	if err != nil {
+		problems = append(problems, err)
+	}
+	_, err = c.FetchTimeout()
+	if err != nil {
+		problems = append(problems, err)
+	}
+	return problems
+}
+
+// Find problems in a config file: whether it is valid JSON, has keys that
+// are not settings, and the problems Check finds. The error is only set if
+// the file cannot be read.
+func Validate(filename string) ([]error, error) {
+	contents, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := map[string]json.RawMessage{}
+	err = json.Unmarshal(contents, &fields)
+	if err != nil {
+		return []error{describeJSONError(contents, err)}, nil
+	}
+
+	problems := []error{}
+	keys := Keys()
+	unknown := []string{}
+	for key := range fields {
+		if !slices.Contains(keys, key) {
+			unknown = append(unknown, key)
+		}
+	}
+	slices.Sort(unknown)
+	for _, key := range unknown {
+		problems = append(problems, fmt.Errorf("unknown setting '%v'", key))
+	}
+
+	config := Config{}
+	err = json.Unmarshal(contents, &config)
+	if err != nil {
+		return append(problems, describeJSONError(contents, err)), nil
+	}
+	return append(problems, config.Check()...), nil
+}
+
+// Bounds on how often a feed is fetched, using the defaults if not set
+func (c *Config) PollIntervals() (time.Duration, time.Duration, error) {
+	minInterval := DefaultMinPollInterval
+	maxInterval := DefaultMaxPollInterval
+	var err error
+	if len(c.Min_poll_interval) > 0 {
+		minInterval, err = time.ParseDuration(c.Min_poll_interval)
+		if err != nil {
+			return 0, 0, fmt.Errorf("min_poll_interval: %w", err)
+		}
+	}
+	if len(c.Max_poll_interval) > 0 {
+		maxInterval, err = time.ParseDuration(c.Max_poll_interval)
+		if err != nil {
+			return 0, 0, fmt.Errorf("max_poll_interval: %w", err)
+		}
+	}
+	if minInterval > maxInterval {
+		return 0, 0, fmt.Errorf("min_poll_interval is greater than max_poll_interval")
+	}
+	return minInterval, maxInterval, nil
+}
+
+func (c *Config) FetchTimeout() (time.Duration, error) {
+	if len(c.Fetch_timeout) == 0 {
+		return DefaultFetchTimeout, nil
+	}
+	timeout, err := time.ParseDuration(c.Fetch_timeout)
+	if err != nil {
+		return 0, fmt.Errorf("fetch_timeout: %w", err)
+	}
+	return timeout, nil
+}
+
+func (c *Config) MaxBodySize() int64 {
+	if c.Fetch_max_body_size <= 0 {
+		return DefaultMaxBodySize
+	}
+	return c.Fetch_max_body_size
+}
+
+// Where the daemon keeps its PID file, by default in $XDG_RUNTIME_DIR. Without
+// that it goes in $XDG_STATE_HOME/gator rather than a shared directory such
+// as /tmp, where another user could put something in its place.
+func (c *Config) PIDFile() (string, error) {
+	if len(c.Daemon_pid_file) > 0 {
+		return c.Daemon_pid_file, nil
+	}
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); len(dir) > 0 {
+		return filepath.Join(dir, "gator.pid"), nil
+	}
+	dir := os.Getenv("XDG_STATE_HOME")
+	if len(dir) == 0 {
+		home_dir, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home_dir, ".local", "state")
+	}
+	return filepath.Join(dir, "gator", "gator.pid"), nil
+}
+
+func (c *Config) DaemonListenAddress() string {
+	if len(c.Daemon_listen_address) == 0 {
+		return DefaultDaemonListenAddress
+	}
+	return c.Daemon_listen_address
+}
+
+// Where the shell keeps its history, by default ~/.gator_history
+func (c *Config) ShellHistoryFile() (string, error) {
+	if len(c.Shell_history_file) > 0 {
+		return c.Shell_history_file, nil
+	}
+	home_dir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home_dir, ".gator_history"), nil
+}
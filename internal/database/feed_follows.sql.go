@@ -132,3 +132,21 @@ func (q *Queries) GetFeedFollowsForUser(ctx context.Context, id uuid.UUID) ([]Ge
 	}
 	return items, nil
 }
+
+const moveFeedFollows = `-- name: MoveFeedFollows :exec
+UPDATE feed_follows
+SET feed_id = $1
+WHERE feed_id = $2 AND user_id NOT IN (
+    SELECT user_id FROM feed_follows
+    WHERE feed_id = $1)
+`
+
+type MoveFeedFollowsParams struct {
+	NewFeedID uuid.UUID
+	OldFeedID uuid.UUID
+}
+
+func (q *Queries) MoveFeedFollows(ctx context.Context, arg MoveFeedFollowsParams) error {
+	_, err := q.db.ExecContext(ctx, moveFeedFollows, arg.NewFeedID, arg.OldFeedID)
+	return err
+}
@@ -23,7 +23,7 @@ VALUES (
     $5,
     $6
 )
-RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, title, site_url, description, language, image_url, next_fetch_at, poll_interval_seconds
 `
 
 type CreateFeedParams struct {
@@ -53,10 +53,27 @@ func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, e
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.Title,
+		&i.SiteUrl,
+		&i.Description,
+		&i.Language,
+		&i.ImageUrl,
+		&i.NextFetchAt,
+		&i.PollIntervalSeconds,
 	)
 	return i, err
 }
 
+const deleteFeed = `-- name: DeleteFeed :exec
+DELETE FROM feeds
+WHERE id = $1
+`
+
+func (q *Queries) DeleteFeed(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteFeed, id)
+	return err
+}
+
 const getFeedByURL = `-- name: GetFeedByURL :one
 SELECT name, id
 FROM feeds
@@ -76,16 +93,24 @@ func (q *Queries) GetFeedByURL(ctx context.Context, url string) (GetFeedByURLRow
 }
 
 const getFeeds = `-- name: GetFeeds :many
-SELECT feeds.name AS name, feeds.url AS url, users.name AS username
+SELECT feeds.name AS name, feeds.url AS url, users.name AS username,
+    feeds.title, feeds.site_url, feeds.description, feeds.language,
+    feeds.image_url, feeds.id
 FROM feeds
 LEFT JOIN users
 ON feeds.user_id = users.id
 `
 
 type GetFeedsRow struct {
-	Name     string
-	Url      string
-	Username sql.NullString
+	Name        string
+	Url         string
+	Username    sql.NullString
+	Title       sql.NullString
+	SiteUrl     sql.NullString
+	Description sql.NullString
+	Language    sql.NullString
+	ImageUrl    sql.NullString
+	ID          uuid.UUID
 }
 
 func (q *Queries) GetFeeds(ctx context.Context) ([]GetFeedsRow, error) {
@@ -97,7 +122,17 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]GetFeedsRow, error) {
 	var items []GetFeedsRow
 	for rows.Next() {
 		var i GetFeedsRow
-		if err := rows.Scan(&i.Name, &i.Url, &i.Username); err != nil {
+		if err := rows.Scan(
+			&i.Name,
+			&i.Url,
+			&i.Username,
+			&i.Title,
+			&i.SiteUrl,
+			&i.Description,
+			&i.Language,
+			&i.ImageUrl,
+			&i.ID,
+		); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -110,3 +145,86 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]GetFeedsRow, error) {
 	}
 	return items, nil
 }
+
+const updateFeedMetadata = `-- name: UpdateFeedMetadata :one
+UPDATE feeds
+SET title = $1, site_url = $2, description = $3, language = $4,
+    image_url = $5, updated_at = $6
+WHERE id = $7
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, title, site_url, description, language, image_url, next_fetch_at, poll_interval_seconds
+`
+
+type UpdateFeedMetadataParams struct {
+	Title       sql.NullString
+	SiteUrl     sql.NullString
+	Description sql.NullString
+	Language    sql.NullString
+	ImageUrl    sql.NullString
+	UpdatedAt   time.Time
+	ID          uuid.UUID
+}
+
+func (q *Queries) UpdateFeedMetadata(ctx context.Context, arg UpdateFeedMetadataParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, updateFeedMetadata,
+		arg.Title,
+		arg.SiteUrl,
+		arg.Description,
+		arg.Language,
+		arg.ImageUrl,
+		arg.UpdatedAt,
+		arg.ID,
+	)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.Title,
+		&i.SiteUrl,
+		&i.Description,
+		&i.Language,
+		&i.ImageUrl,
+		&i.NextFetchAt,
+		&i.PollIntervalSeconds,
+	)
+	return i, err
+}
+
+const updateFeedURL = `-- name: UpdateFeedURL :one
+UPDATE feeds
+SET url = $1, updated_at = $2
+WHERE id = $3
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, title, site_url, description, language, image_url, next_fetch_at, poll_interval_seconds
+`
+
+type UpdateFeedURLParams struct {
+	Url       string
+	UpdatedAt time.Time
+	ID        uuid.UUID
+}
+
+func (q *Queries) UpdateFeedURL(ctx context.Context, arg UpdateFeedURLParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, updateFeedURL, arg.Url, arg.UpdatedAt, arg.ID)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.Title,
+		&i.SiteUrl,
+		&i.Description,
+		&i.Language,
+		&i.ImageUrl,
+		&i.NextFetchAt,
+		&i.PollIntervalSeconds,
+	)
+	return i, err
+}
@@ -8,17 +8,163 @@ package database
 import (
 	"context"
 	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
 )
 
-const getStalestFeed = `-- name: GetStalestFeed :one
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at FROM feeds
-ORDER BY last_fetched_at ASC NULLS FIRST LIMIT 1
+const countOverdueFeeds = `-- name: CountOverdueFeeds :one
+SELECT COUNT(*) FROM feeds
+WHERE next_fetch_at IS NULL OR next_fetch_at <= $1
 `
 
-func (q *Queries) GetStalestFeed(ctx context.Context) (Feed, error) {
-	row := q.db.QueryRowContext(ctx, getStalestFeed)
+func (q *Queries) CountOverdueFeeds(ctx context.Context, nextFetchAt sql.NullTime) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countOverdueFeeds, nextFetchAt)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const getAllFeeds = `-- name: GetAllFeeds :many
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, title, site_url, description, language, image_url, next_fetch_at, poll_interval_seconds FROM feeds
+ORDER BY name ASC
+`
+
+func (q *Queries) GetAllFeeds(ctx context.Context) ([]Feed, error) {
+	rows, err := q.db.QueryContext(ctx, getAllFeeds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Feed
+	for rows.Next() {
+		var i Feed
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Name,
+			&i.Url,
+			&i.UserID,
+			&i.LastFetchedAt,
+			&i.Title,
+			&i.SiteUrl,
+			&i.Description,
+			&i.Language,
+			&i.ImageUrl,
+			&i.NextFetchAt,
+			&i.PollIntervalSeconds,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getFeedSchedule = `-- name: GetFeedSchedule :many
+SELECT name, url, last_fetched_at, next_fetch_at, poll_interval_seconds
+FROM feeds
+ORDER BY next_fetch_at ASC NULLS FIRST
+`
+
+type GetFeedScheduleRow struct {
+	Name                string
+	Url                 string
+	LastFetchedAt       sql.NullTime
+	NextFetchAt         sql.NullTime
+	PollIntervalSeconds sql.NullInt32
+}
+
+func (q *Queries) GetFeedSchedule(ctx context.Context) ([]GetFeedScheduleRow, error) {
+	rows, err := q.db.QueryContext(ctx, getFeedSchedule)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetFeedScheduleRow
+	for rows.Next() {
+		var i GetFeedScheduleRow
+		if err := rows.Scan(
+			&i.Name,
+			&i.Url,
+			&i.LastFetchedAt,
+			&i.NextFetchAt,
+			&i.PollIntervalSeconds,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getFollowedFeeds = `-- name: GetFollowedFeeds :many
+SELECT feeds.id, feeds.created_at, feeds.updated_at, feeds.name, feeds.url, feeds.user_id, feeds.last_fetched_at, feeds.title, feeds.site_url, feeds.description, feeds.language, feeds.image_url, feeds.next_fetch_at, feeds.poll_interval_seconds FROM feeds
+INNER JOIN feed_follows ON feed_follows.feed_id = feeds.id
+WHERE feed_follows.user_id = $1
+ORDER BY feeds.name ASC
+`
+
+func (q *Queries) GetFollowedFeeds(ctx context.Context, userID uuid.UUID) ([]Feed, error) {
+	rows, err := q.db.QueryContext(ctx, getFollowedFeeds, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Feed
+	for rows.Next() {
+		var i Feed
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Name,
+			&i.Url,
+			&i.UserID,
+			&i.LastFetchedAt,
+			&i.Title,
+			&i.SiteUrl,
+			&i.Description,
+			&i.Language,
+			&i.ImageUrl,
+			&i.NextFetchAt,
+			&i.PollIntervalSeconds,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getNextFeedToFetch = `-- name: GetNextFeedToFetch :one
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, title, site_url, description, language, image_url, next_fetch_at, poll_interval_seconds FROM feeds
+WHERE next_fetch_at IS NULL OR next_fetch_at <= $1
+ORDER BY next_fetch_at ASC NULLS FIRST, last_fetched_at ASC NULLS FIRST
+LIMIT 1
+`
+
+func (q *Queries) GetNextFeedToFetch(ctx context.Context, nextFetchAt sql.NullTime) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, getNextFeedToFetch, nextFetchAt)
 	var i Feed
 	err := row.Scan(
 		&i.ID,
@@ -28,15 +174,56 @@ func (q *Queries) GetStalestFeed(ctx context.Context) (Feed, error) {
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.Title,
+		&i.SiteUrl,
+		&i.Description,
+		&i.Language,
+		&i.ImageUrl,
+		&i.NextFetchAt,
+		&i.PollIntervalSeconds,
 	)
 	return i, err
 }
 
+const getRecentPostTimes = `-- name: GetRecentPostTimes :many
+SELECT published_at FROM posts
+WHERE feed_id = $1
+ORDER BY published_at DESC LIMIT $2
+`
+
+type GetRecentPostTimesParams struct {
+	FeedID uuid.UUID
+	Limit  int32
+}
+
+func (q *Queries) GetRecentPostTimes(ctx context.Context, arg GetRecentPostTimesParams) ([]time.Time, error) {
+	rows, err := q.db.QueryContext(ctx, getRecentPostTimes, arg.FeedID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []time.Time
+	for rows.Next() {
+		var published_at time.Time
+		if err := rows.Scan(&published_at); err != nil {
+			return nil, err
+		}
+		items = append(items, published_at)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const markFeedFetched = `-- name: MarkFeedFetched :one
 UPDATE feeds
 SET last_fetched_at = $1, updated_at = $1
 WHERE id = $2
-RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, title, site_url, description, language, image_url, next_fetch_at, poll_interval_seconds
 `
 
 type MarkFeedFetchedParams struct {
@@ -55,6 +242,48 @@ func (q *Queries) MarkFeedFetched(ctx context.Context, arg MarkFeedFetchedParams
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.Title,
+		&i.SiteUrl,
+		&i.Description,
+		&i.Language,
+		&i.ImageUrl,
+		&i.NextFetchAt,
+		&i.PollIntervalSeconds,
+	)
+	return i, err
+}
+
+const setFeedNextFetch = `-- name: SetFeedNextFetch :one
+UPDATE feeds
+SET next_fetch_at = $1, poll_interval_seconds = $2
+WHERE id = $3
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, title, site_url, description, language, image_url, next_fetch_at, poll_interval_seconds
+`
+
+type SetFeedNextFetchParams struct {
+	NextFetchAt         sql.NullTime
+	PollIntervalSeconds sql.NullInt32
+	ID                  uuid.UUID
+}
+
+func (q *Queries) SetFeedNextFetch(ctx context.Context, arg SetFeedNextFetchParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, setFeedNextFetch, arg.NextFetchAt, arg.PollIntervalSeconds, arg.ID)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.Title,
+		&i.SiteUrl,
+		&i.Description,
+		&i.Language,
+		&i.ImageUrl,
+		&i.NextFetchAt,
+		&i.PollIntervalSeconds,
 	)
 	return i, err
 }
@@ -11,14 +11,62 @@ import (
 	"github.com/google/uuid"
 )
 
+type Enclosure struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Url       string
+	MimeType  sql.NullString
+	Length    sql.NullInt64
+	Duration  sql.NullInt32
+	PostID    uuid.UUID
+}
+
 type Feed struct {
-	ID            uuid.UUID
-	CreatedAt     time.Time
-	UpdatedAt     time.Time
-	Name          string
-	Url           string
-	UserID        uuid.UUID
-	LastFetchedAt sql.NullTime
+	ID                  uuid.UUID
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+	Name                string
+	Url                 string
+	UserID              uuid.UUID
+	LastFetchedAt       sql.NullTime
+	Title               sql.NullString
+	SiteUrl             sql.NullString
+	Description         sql.NullString
+	Language            sql.NullString
+	ImageUrl            sql.NullString
+	NextFetchAt         sql.NullTime
+	PollIntervalSeconds sql.NullInt32
+}
+
+type FeedCredential struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Kind      string
+	Name      string
+	Value     string
+	FeedID    uuid.UUID
+}
+
+type FeedEvent struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Kind      string
+	Detail    string
+	FeedID    uuid.UUID
+}
+
+type FeedFetch struct {
+	ID               uuid.UUID
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	StatusCode       int32
+	ContentEncoding  string
+	BytesTransferred int64
+	BodySize         int64
+	FeedID           uuid.UUID
 }
 
 type FeedFollow struct {
@@ -38,6 +86,20 @@ type Post struct {
 	Description sql.NullString
 	PublishedAt time.Time
 	FeedID      uuid.UUID
+	Guid        string
+	ContentHash string
+	LegacyHash  bool
+}
+
+type PostRevision struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Title       sql.NullString
+	Url         string
+	Description sql.NullString
+	ContentHash string
+	PostID      uuid.UUID
 }
 
 type User struct {
@@ -13,44 +13,68 @@ import (
 	"github.com/google/uuid"
 )
 
-const createPost = `-- name: CreatePost :one
-INSERT INTO posts (id, created_at, updated_at, title, url, description,
-    published_at, feed_id)
-VALUES (
-    $1,
-    $2,
-    $3,
-    $4,
-    $5,
-    $6,
-    $7,
-    $8
-)
-RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id
+const getPost = `-- name: GetPost :one
+SELECT id, created_at, updated_at, title, url, description, published_at, feed_id, guid, content_hash, legacy_hash FROM posts
+WHERE id = $1
 `
 
-type CreatePostParams struct {
-	ID          uuid.UUID
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	Title       sql.NullString
-	Url         string
-	Description sql.NullString
-	PublishedAt time.Time
-	FeedID      uuid.UUID
+func (q *Queries) GetPost(ctx context.Context, id uuid.UUID) (Post, error) {
+	row := q.db.QueryRowContext(ctx, getPost, id)
+	var i Post
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Title,
+		&i.Url,
+		&i.Description,
+		&i.PublishedAt,
+		&i.FeedID,
+		&i.Guid,
+		&i.ContentHash,
+		&i.LegacyHash,
+	)
+	return i, err
 }
 
-func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, error) {
-	row := q.db.QueryRowContext(ctx, createPost,
-		arg.ID,
-		arg.CreatedAt,
-		arg.UpdatedAt,
-		arg.Title,
-		arg.Url,
-		arg.Description,
-		arg.PublishedAt,
-		arg.FeedID,
+const getPostByGUID = `-- name: GetPostByGUID :one
+SELECT id, created_at, updated_at, title, url, description, published_at, feed_id, guid, content_hash, legacy_hash FROM posts
+WHERE feed_id = $1 AND guid = $2
+FOR UPDATE
+`
+
+type GetPostByGUIDParams struct {
+	FeedID uuid.UUID
+	Guid   string
+}
+
+func (q *Queries) GetPostByGUID(ctx context.Context, arg GetPostByGUIDParams) (Post, error) {
+	row := q.db.QueryRowContext(ctx, getPostByGUID, arg.FeedID, arg.Guid)
+	var i Post
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Title,
+		&i.Url,
+		&i.Description,
+		&i.PublishedAt,
+		&i.FeedID,
+		&i.Guid,
+		&i.ContentHash,
+		&i.LegacyHash,
 	)
+	return i, err
+}
+
+const getPostByURL = `-- name: GetPostByURL :one
+SELECT id, created_at, updated_at, title, url, description, published_at, feed_id, guid, content_hash, legacy_hash FROM posts
+WHERE url = $1
+ORDER BY published_at DESC LIMIT 1
+`
+
+func (q *Queries) GetPostByURL(ctx context.Context, url string) (Post, error) {
+	row := q.db.QueryRowContext(ctx, getPostByURL, url)
 	var i Post
 	err := row.Scan(
 		&i.ID,
@@ -61,12 +85,18 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 		&i.Description,
 		&i.PublishedAt,
 		&i.FeedID,
+		&i.Guid,
+		&i.ContentHash,
+		&i.LegacyHash,
 	)
 	return i, err
 }
 
 const getPostsForUser = `-- name: GetPostsForUser :many
-SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, feeds.name as feed_name FROM posts
+SELECT posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id, posts.guid, posts.content_hash, posts.legacy_hash, feeds.name as feed_name,
+    (SELECT COUNT(*) FROM post_revisions
+    WHERE post_revisions.post_id = posts.id) AS revisions
+FROM posts
 JOIN feed_follows
 ON posts.feed_id = feed_follows.feed_id
 JOIN users
@@ -91,7 +121,11 @@ type GetPostsForUserRow struct {
 	Description sql.NullString
 	PublishedAt time.Time
 	FeedID      uuid.UUID
+	Guid        string
+	ContentHash string
+	LegacyHash  bool
 	FeedName    string
+	Revisions   int64
 }
 
 func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams) ([]GetPostsForUserRow, error) {
@@ -112,7 +146,11 @@ func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams
 			&i.Description,
 			&i.PublishedAt,
 			&i.FeedID,
+			&i.Guid,
+			&i.ContentHash,
+			&i.LegacyHash,
 			&i.FeedName,
+			&i.Revisions,
 		); err != nil {
 			return nil, err
 		}
@@ -126,3 +164,107 @@ func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams
 	}
 	return items, nil
 }
+
+const movePosts = `-- name: MovePosts :exec
+UPDATE posts
+SET feed_id = $1
+WHERE feed_id = $2 AND guid NOT IN (
+    SELECT guid FROM posts
+    WHERE feed_id = $1)
+`
+
+type MovePostsParams struct {
+	NewFeedID uuid.UUID
+	OldFeedID uuid.UUID
+}
+
+func (q *Queries) MovePosts(ctx context.Context, arg MovePostsParams) error {
+	_, err := q.db.ExecContext(ctx, movePosts, arg.NewFeedID, arg.OldFeedID)
+	return err
+}
+
+const updatePostGUID = `-- name: UpdatePostGUID :exec
+UPDATE posts
+SET guid = $2
+WHERE id = $1
+`
+
+type UpdatePostGUIDParams struct {
+	ID   uuid.UUID
+	Guid string
+}
+
+func (q *Queries) UpdatePostGUID(ctx context.Context, arg UpdatePostGUIDParams) error {
+	_, err := q.db.ExecContext(ctx, updatePostGUID, arg.ID, arg.Guid)
+	return err
+}
+
+const upsertPost = `-- name: UpsertPost :one
+INSERT INTO posts (id, created_at, updated_at, title, url, description,
+    published_at, feed_id, guid, content_hash)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8,
+    $9,
+    $10
+)
+ON CONFLICT (feed_id, guid) DO UPDATE
+SET title = excluded.title, url = excluded.url,
+    description = excluded.description, content_hash = excluded.content_hash,
+    legacy_hash = FALSE,
+    updated_at = CASE
+        WHEN posts.content_hash <> excluded.content_hash AND NOT posts.legacy_hash
+        THEN excluded.updated_at
+        ELSE posts.updated_at
+    END
+RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id, guid, content_hash, legacy_hash
+`
+
+type UpsertPostParams struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Title       sql.NullString
+	Url         string
+	Description sql.NullString
+	PublishedAt time.Time
+	FeedID      uuid.UUID
+	Guid        string
+	ContentHash string
+}
+
+func (q *Queries) UpsertPost(ctx context.Context, arg UpsertPostParams) (Post, error) {
+	row := q.db.QueryRowContext(ctx, upsertPost,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.Title,
+		arg.Url,
+		arg.Description,
+		arg.PublishedAt,
+		arg.FeedID,
+		arg.Guid,
+		arg.ContentHash,
+	)
+	var i Post
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Title,
+		&i.Url,
+		&i.Description,
+		&i.PublishedAt,
+		&i.FeedID,
+		&i.Guid,
+		&i.ContentHash,
+		&i.LegacyHash,
+	)
+	return i, err
+}
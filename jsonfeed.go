@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type JSONFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	Description string         `json:"description"`
+	Language    string         `json:"language"`
+	Icon        string         `json:"icon"`
+	Favicon     string         `json:"favicon"`
+	Items       []JSONFeedItem `json:"items"`
+}
+
+type JSONFeedItem struct {
+	// Meant to be a string, but some publishers use numbers
+	ID            json.RawMessage      `json:"id"`
+	URL           string               `json:"url"`
+	Title         string               `json:"title"`
+	ContentHTML   string               `json:"content_html"`
+	ContentText   string               `json:"content_text"`
+	Summary       string               `json:"summary"`
+	DatePublished string               `json:"date_published"`
+	DateModified  string               `json:"date_modified"`
+	Attachments   []JSONFeedAttachment `json:"attachments"`
+}
+
+// The item's ID, whether it was given as a string or a number
+func (item JSONFeedItem) id() string {
+	var id string
+	if json.Unmarshal(item.ID, &id) == nil {
+		return strings.TrimSpace(id)
+	}
+	var number json.Number
+	if json.Unmarshal(item.ID, &number) == nil {
+		return number.String()
+	}
+	return ""
+}
+
+type JSONFeedAttachment struct {
+	URL               string  `json:"url"`
+	MimeType          string  `json:"mime_type"`
+	SizeInBytes       int64   `json:"size_in_bytes"`
+	DurationInSeconds float64 `json:"duration_in_seconds"`
+}
+
+func (f JSONFeed) toRSS() (RSSFeed, error) {
+	if !strings.HasPrefix(f.Version, "https://jsonfeed.org/version/") {
+		return RSSFeed{}, fmt.Errorf("Unknown JSON feed version: '%v'", f.Version)
+	}
+
+	result := RSSFeed{}
+	// Titles are plain text, with no entities to unescape
+	result.Channel.Title = cleanLine(f.Title)
+	result.Channel.Link = f.HomePageURL
+	result.Channel.Description = html.EscapeString(f.Description)
+	result.Channel.Language = f.Language
+	result.Channel.Image.URL = f.Icon
+	if len(result.Channel.Image.URL) == 0 {
+		result.Channel.Image.URL = f.Favicon
+	}
+
+	for _, entry := range f.Items {
+		item := RSSItem{
+			Title:       cleanLine(entry.Title),
+			Link:        entry.URL,
+			Description: entry.ContentHTML,
+			GUID:        entry.id(),
+		}
+		if len(item.Description) == 0 {
+			item.Description = html.EscapeString(entry.ContentText)
+		}
+		if len(item.Description) == 0 {
+			item.Description = html.EscapeString(entry.Summary)
+		}
+		for _, date := range []string{entry.DatePublished, entry.DateModified} {
+			t, err := time.Parse(time.RFC3339, date)
+			if err == nil {
+				item.PubDate = t.Format(time.RFC1123Z)
+				break
+			}
+		}
+		for _, attachment := range entry.Attachments {
+			item.Enclosure = append(item.Enclosure, RSSEnclosure{
+				URL:    attachment.URL,
+				Length: strconv.FormatInt(attachment.SizeInBytes, 10),
+				Type:   attachment.MimeType,
+			})
+			if len(item.ITunesDuration) == 0 && attachment.DurationInSeconds > 0 {
+				item.ITunesDuration = strconv.Itoa(int(attachment.DurationInSeconds))
+			}
+		}
+		result.Channel.Item = append(result.Channel.Item, item)
+	}
+
+	return result, nil
+}
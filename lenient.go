@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// Characters that are not allowed anywhere in an XML 1.0 document
+func isXMLControlChar(r rune) bool {
+	return (r < 0x20 && r != '\t' && r != '\n' && r != '\r') ||
+		r == 0xFFFE || r == 0xFFFF
+}
+
+// Fix the problems a non-strict xml.Decoder cannot cope with by itself.
+// Control characters are dropped, and bytes that are not valid UTF-8 are
+// assumed to be Windows-1252, which is what feeds that get their charset
+// wrong are almost always in. The result is always UTF-8.
+func repairXML(data []byte) []byte {
+	// Decode the charset named in the XML declaration first, and say the
+	// result is UTF-8, or the decoder would decode it a second time
+	match := xmlEncodingDeclaration.FindSubmatch(data)
+	if match != nil {
+		encoding, name := charset.Lookup(strings.Trim(string(match[2]), `"'`))
+		if encoding != nil {
+			if name != "utf-8" {
+				decoded, err := encoding.NewDecoder().Bytes(data)
+				if err == nil {
+					data = decoded
+				}
+			}
+			data = xmlEncodingDeclaration.ReplaceAll(data, []byte(`${1}"UTF-8"`))
+		}
+	}
+
+	if utf8.Valid(data) && bytes.IndexFunc(data, isXMLControlChar) < 0 {
+		return data
+	}
+
+	result := make([]byte, 0, len(data))
+	for len(data) > 0 {
+		r, size := utf8.DecodeRune(data)
+		if r == utf8.RuneError && size == 1 {
+			r = charmap.Windows1252.DecodeByte(data[0])
+		}
+		if !isXMLControlChar(r) {
+			result = utf8.AppendRune(result, r)
+		}
+		data = data[size:]
+	}
+	return result
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultLogLevel  = "info"
+	defaultLogFormat = "text"
+)
+
+var logLevels = map[string]slog.Level{
+	"debug":   slog.LevelDebug,
+	"info":    slog.LevelInfo,
+	"warn":    slog.LevelWarn,
+	"warning": slog.LevelWarn,
+	"error":   slog.LevelError,
+}
+
+// Diagnostics are logged separately from the output of commands, which goes
+// to stdout
+func newLogger(w io.Writer, level, format string) (*slog.Logger, error) {
+	logLevel, ok := logLevels[strings.ToLower(level)]
+	if !ok {
+		return nil, fmt.Errorf("Unknown log level '%v', expected debug, info, warn or error", level)
+	}
+
+	options := &slog.HandlerOptions{Level: logLevel}
+	switch strings.ToLower(format) {
+	case "text":
+		return slog.New(slog.NewTextHandler(w, options)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, options)), nil
+	}
+	return nil, fmt.Errorf("Unknown log format '%v', expected text or json", format)
+}
+
+// Log fields identifying a feed
+func feedLogger(logger *slog.Logger, feedID uuid.UUID, feedURL string) *slog.Logger {
+	return logger.With("feed_id", feedID.String(), "url", feedURL)
+}
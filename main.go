@@ -1,9 +1,15 @@
 package main
 
 import (
+	"bufio"
 	"database/sql"
+	"errors"
 	"fmt"
+	"io/fs"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/Tavis7/bootdev-gator/internal/config"
 	"github.com/Tavis7/bootdev-gator/internal/database"
@@ -13,28 +19,102 @@ import _ "github.com/lib/pq"
 
 type state struct {
 	config   *config.Config
+	db       *sql.DB
 	database *database.Queries
 	commands *commands
+	fetcher  *fetcher
+	// From --output
+	output string
+	// Where answers to questions, such as which feed to add, are read from
+	input *bufio.Scanner
+}
+
+// Options that work with every command, wherever they are on the command
+// line
+var globalFlags = []flagDef{
+	stringFlag("config", "path", "", "Read settings from this file instead of the default, see README"),
+	stringFlag("user", "name", "", "Act as this user, without logging in as them"),
+	stringFlag("db-url", "url", "", "Connect to this database instead of db_url"),
+	stringFlag("output", "format", "text", "How to print results: "+strings.Join(outputFormats, ", ")),
+	stringFlag("log-level", "level", defaultLogLevel, "How much to log: debug, info, warn or error"),
+	stringFlag("log-format", "format", defaultLogFormat, "How to log: text or json"),
 }
 
 func main() {
-	conf, err := config.Read()
+	options, args, err := parseFlags(globalFlags, os.Args[1:], true)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	logger, err := newLogger(os.Stderr,
+		options["log-level"].(string), options["log-format"].(string))
 	if err != nil {
-		fmt.Println(err)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	slog.SetDefault(logger)
+
+	output := options["output"].(string)
+	err = checkOutputFormat(output)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// `config` has to work without a usable config file, to be able to fix it
+	configuring := len(args) > 0 && args[0] == "config"
+
+	var conf config.Config
+	if configFile := options["config"].(string); len(configFile) > 0 {
+		conf, err = config.ReadFile(configFile)
+	} else {
+		conf, err = config.Read()
+	}
+	// A missing file is fine if the settings are all in the environment
+	missing := errors.Is(err, fs.ErrNotExist)
+	if err != nil && !missing && !configuring {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	err = conf.ApplyEnv()
+	if err != nil && !configuring {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if dbURL := options["db-url"].(string); len(dbURL) > 0 {
+		conf.Db_url = dbURL
+	}
+	if user := options["user"].(string); len(user) > 0 {
+		conf.Current_user_name = user
+	}
+	if missing && len(conf.Db_url) == 0 && !configuring {
+		path, _ := conf.Path()
+		fmt.Fprintf(os.Stderr, "Error: %v does not exist, run `%v config init` to create it or set %v\n",
+			path, filepath.Base(os.Args[0]), config.EnvPrefix+"DB_URL")
 		os.Exit(1)
 	}
 
 	s := state{
 		config: &conf,
+		output: output,
+		input:  bufio.NewScanner(os.Stdin),
+	}
+
+	s.fetcher, err = newFetcher(s.config)
+	if err != nil && !configuring {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
 
 	db, err := sql.Open("postgres", s.config.Db_url)
 	if err != nil {
-		fmt.Println(err)
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 
-	dbQueries := database.New(db)
+	s.db = db
+	dbQueries := database.New(instrumentedDB{db: db})
 	s.database = dbQueries
 
 	s.commands = &commands{
@@ -56,13 +136,33 @@ func main() {
 		handlerUsers)
 	commandList.register("agg", "<delay>",
 		"Refresh one old feed every <delay> seconds, minutes, hours, etc.",
-		handlerAgg)
+		handlerAgg,
+		stringFlag("metrics", "address", "", "Serve Prometheus metrics on this address"))
+	commandList.register("daemon", "<delay>",
+		"Run agg as a service, with health checks, see README",
+		handlerDaemon)
+	commandList.register("refresh", "[<url>...]",
+		"Fetch the given feeds now, or every feed you follow",
+		handlerRefresh,
+		boolFlag("all", "Fetch every feed"),
+		boolFlag("following", "Fetch the feeds you follow, as well as any given"))
 	commandList.register("addfeed", "<name> <url>",
 		"Add and follow feed",
 		middlewareLoggedIn(handlerAddFeed))
 	commandList.register("feeds", "",
-		"List feeds",
-		handlerListFeeds)
+		"List feeds, when each feed will next be fetched, or how much each has downloaded",
+		handlerListFeeds,
+		boolFlag("schedule", "Show when each feed was last fetched and will next be fetched"),
+		boolFlag("bandwidth", "Show how much each feed has downloaded in the last 30 days"))
+	commandList.register("fetch", "<url>",
+		"Fetch and parse a feed without saving anything, to debug it",
+		handlerFetch,
+		boolFlag("raw", "Print the response body instead of parsing it"),
+		boolFlag("parsed", "Print the feed as it would be saved, the default"),
+		boolFlag("json", "Print everything as JSON"))
+	commandList.register("events", "<url>",
+		"List the history of a feed, such as when it moved",
+		handlerEvents)
 	commandList.register("follow", "<url>",
 		"Follow a feed",
 		middlewareLoggedIn(handlerFollow))
@@ -73,26 +173,46 @@ func main() {
 		"Unfollow a feed you are following",
 		middlewareLoggedIn(handlerUnfollow))
 	commandList.register("browse", "[<limit>]",
-		"List the last <limit> posts from feeds you are following, default 2",
-		middlewareLoggedIn(handlerBrowse))
-	commandList.register("help", "",
-		"Print this help and exit",
+		"List the latest posts from feeds you are following",
+		middlewareLoggedIn(handlerBrowse),
+		intFlag("limit", "n", 2, "How many posts to list"))
+	commandList.register("download", "<post>",
+		"Download the enclosures of a post, given its ID or URL",
+		handlerDownload)
+	commandList.register("auth", "<url> [<kind> <credentials>...]",
+		"Show or set the credentials used to fetch a feed, see README",
+		handlerAuth)
+	commandList.register("diff", "<post>",
+		"Show how a post has changed since it was first fetched",
+		handlerDiff)
+	commandList.register("shell", "",
+		"Run commands one after another at an interactive prompt",
+		handlerShell)
+	commandList.register("config", "<action> [<key> [<value>]]",
+		"Set up the config file with `init`, or `get`, `set` or `validate` its settings",
+		handlerConfig,
+		boolFlag("force", "Let `init` replace an existing config file"))
+	commandList.register("completion", "<shell>",
+		"Print a script that completes commands in bash, zsh or fish, see README",
+		handlerCompletion)
+	commandList.register("help", "[<command>]",
+		"Print this help, or details of how to use <command>, and exit",
 		handlerHelp)
+	commandList.registerHidden("__complete", "[<word>...]", handlerComplete)
 
-	args := os.Args
-	if len(args) < 2 {
-		fmt.Printf("Error: expected at least one command line argument\n")
+	if len(args) < 1 {
+		fmt.Fprintf(os.Stderr, "Error: expected at least one command line argument\n")
 		os.Exit(1)
 	}
 
 	cmd := command{
-		name: args[1],
-		args: args[2:],
+		name: args[0],
+		args: args[1:],
 	}
 
 	err = commandList.run(&s, cmd)
 	if err != nil {
-		fmt.Printf("Error: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Tavis7/bootdev-gator/internal/database"
+)
+
+// Metrics in the Prometheus text exposition format, see
+// https://prometheus.io/docs/instrumenting/exposition_formats/
+
+type metricFamily interface {
+	write(w io.Writer)
+}
+
+func escapeLabelValue(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	return strings.ReplaceAll(value, "\n", `\n`)
+}
+
+func formatLabels(names, values []string, extra ...string) string {
+	pairs := []string{}
+	for i, name := range names {
+		pairs = append(pairs, fmt.Sprintf(`%v="%v"`, name, escapeLabelValue(values[i])))
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, fmt.Sprintf(`%v="%v"`, extra[i], escapeLabelValue(extra[i+1])))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatFloat(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func writeHeader(w io.Writer, name, help, kind string) {
+	fmt.Fprintf(w, "# HELP %v %v\n# TYPE %v %v\n", name, help, name, kind)
+}
+
+// A counter for each combination of label values
+type counterVec struct {
+	name   string
+	help   string
+	labels []string
+	mutex  sync.Mutex
+	values map[string]float64
+}
+
+func newCounterVec(name, help string, labels ...string) *counterVec {
+	return &counterVec{name: name, help: help, labels: labels, values: map[string]float64{}}
+}
+
+func (c *counterVec) add(value float64, labelValues ...string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.values[formatLabels(c.labels, labelValues)] += value
+}
+
+func (c *counterVec) write(w io.Writer) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	writeHeader(w, c.name, c.help, "counter")
+	if len(c.labels) == 0 && len(c.values) == 0 {
+		fmt.Fprintf(w, "%v 0\n", c.name)
+	}
+	keys := []string{}
+	for key := range c.values {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		fmt.Fprintf(w, "%v%v %v\n", c.name, key, formatFloat(c.values[key]))
+	}
+}
+
+type histogram struct {
+	labelValues []string
+	// Not cumulative, each observation is only counted in one bucket
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// A histogram for each combination of label values
+type histogramVec struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+	mutex   sync.Mutex
+	series  map[string]*histogram
+}
+
+func newHistogramVec(name, help string, buckets []float64, labels ...string) *histogramVec {
+	return &histogramVec{
+		name:    name,
+		help:    help,
+		labels:  labels,
+		buckets: buckets,
+		series:  map[string]*histogram{},
+	}
+}
+
+func (h *histogramVec) observe(value float64, labelValues ...string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	key := strings.Join(labelValues, "\x00")
+	series, ok := h.series[key]
+	if !ok {
+		series = &histogram{
+			labelValues: labelValues,
+			counts:      make([]uint64, len(h.buckets)+1),
+		}
+		h.series[key] = series
+	}
+	// The last count is for the +Inf bucket
+	i, _ := slices.BinarySearch(h.buckets, value)
+	series.counts[i]++
+	series.sum += value
+	series.count++
+}
+
+func (h *histogramVec) write(w io.Writer) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	writeHeader(w, h.name, h.help, "histogram")
+	keys := []string{}
+	for key := range h.series {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		series := h.series[key]
+		cumulative := uint64(0)
+		for i, count := range series.counts {
+			cumulative += count
+			le := math.Inf(1)
+			if i < len(h.buckets) {
+				le = h.buckets[i]
+			}
+			fmt.Fprintf(w, "%v_bucket%v %v\n", h.name,
+				formatLabels(h.labels, series.labelValues, "le", formatFloat(le)), cumulative)
+		}
+		labels := formatLabels(h.labels, series.labelValues)
+		fmt.Fprintf(w, "%v_sum%v %v\n", h.name, labels, formatFloat(series.sum))
+		fmt.Fprintf(w, "%v_count%v %v\n", h.name, labels, series.count)
+	}
+}
+
+// A gauge whose value is worked out when the metrics are scraped
+type gaugeFunc struct {
+	name  string
+	help  string
+	value func() (float64, error)
+}
+
+func (g *gaugeFunc) write(w io.Writer) {
+	value, err := g.value()
+	if err != nil {
+		// Leave the gauge out rather than report a wrong value
+		fmt.Fprintf(w, "# %v unavailable: %v\n", g.name,
+			strings.ReplaceAll(err.Error(), "\n", " "))
+		return
+	}
+	writeHeader(w, g.name, g.help, "gauge")
+	fmt.Fprintf(w, "%v %v\n", g.name, formatFloat(value))
+}
+
+var (
+	latencyBuckets   = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+	dbLatencyBuckets = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1}
+
+	metricFetches = newCounterVec("gator_fetches_total",
+		`Feed fetches by HTTP status code, or "error" if there was no response`, "status")
+	metricFetchDuration = newHistogramVec("gator_fetch_duration_seconds",
+		"How long fetching a feed took", latencyBuckets)
+	metricFetchBytes = newCounterVec("gator_fetch_bytes_total",
+		"Bytes downloaded when fetching feeds, before decompressing")
+	metricItemsParsed = newCounterVec("gator_items_parsed_total",
+		"Items found in fetched feeds")
+	metricItemsInserted = newCounterVec("gator_items_inserted_total",
+		"Items saved as new posts")
+	metricItemsDuplicated = newCounterVec("gator_items_duplicated_total",
+		"Items that had already been saved")
+	metricParseErrors = newCounterVec("gator_parse_errors_total",
+		"Feeds that could not be parsed", "feed")
+	metricDBQueryDuration = newHistogramVec("gator_db_query_duration_seconds",
+		"How long database queries took", dbLatencyBuckets, "query")
+)
+
+func newMetricsHandler(s *state) http.Handler {
+	overdue := &gaugeFunc{
+		name: "gator_overdue_feeds",
+		help: "Feeds that are due to be fetched",
+		value: func() (float64, error) {
+			count, err := s.database.CountOverdueFeeds(context.Background(),
+				sql.NullTime{Time: time.Now().UTC(), Valid: true})
+			return float64(count), err
+		},
+	}
+	families := []metricFamily{
+		metricFetches,
+		metricFetchDuration,
+		metricFetchBytes,
+		metricItemsParsed,
+		metricItemsInserted,
+		metricItemsDuplicated,
+		metricParseErrors,
+		overdue,
+		metricDBQueryDuration,
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		for _, family := range families {
+			family.write(w)
+		}
+	})
+}
+
+// Record what happened when a feed was fetched
+func observeFetch(result fetchResult, duration time.Duration) {
+	status := "error"
+	if result.response != nil {
+		status = strconv.Itoa(result.response.StatusCode)
+	}
+	metricFetches.add(1, status)
+	metricFetchDuration.observe(duration.Seconds())
+	metricFetchBytes.add(float64(result.bytesTransferred))
+}
+
+// Times the queries made through it, which may be a *sql.DB or a *sql.Tx.
+// Queries generated by sqlc start with their name, which is used as the
+// label.
+type instrumentedDB struct {
+	db database.DBTX
+}
+
+// Queries made in a transaction, timed like all the others
+func queriesInTx(tx *sql.Tx) *database.Queries {
+	return database.New(instrumentedDB{db: tx})
+}
+
+func queryName(query string) string {
+	name, found := strings.CutPrefix(query, "-- name: ")
+	if !found {
+		return "other"
+	}
+	name, _, _ = strings.Cut(name, " ")
+	return name
+}
+
+func observeQuery(query string, start time.Time) {
+	metricDBQueryDuration.observe(time.Since(start).Seconds(), queryName(query))
+}
+
+func (i instrumentedDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	defer observeQuery(query, time.Now())
+	return i.db.ExecContext(ctx, query, args...)
+}
+
+func (i instrumentedDB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	defer observeQuery(query, time.Now())
+	return i.db.PrepareContext(ctx, query)
+}
+
+func (i instrumentedDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	defer observeQuery(query, time.Now())
+	return i.db.QueryContext(ctx, query, args...)
+}
+
+func (i instrumentedDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	defer observeQuery(query, time.Now())
+	return i.db.QueryRowContext(ctx, query, args...)
+}
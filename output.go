@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Formats that --output accepts
+var outputFormats = []string{"text", "json", "jsonl", "csv", "tsv"}
+
+func checkOutputFormat(output string) error {
+	if !slices.Contains(outputFormats, output) {
+		return fmt.Errorf("Unknown output format '%v', expected one of %v",
+			output, strings.Join(outputFormats, ", "))
+	}
+	return nil
+}
+
+// Print the results of a command in the format given by --output. Records
+// are structs, and their json tags name the fields in every format.
+// printText prints one record for --output text.
+func printRecords[T any](s *state, records []T, printText func(T) error) error {
+	switch s.output {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "    ")
+		if records == nil {
+			records = []T{}
+		}
+		return encoder.Encode(records)
+	case "jsonl":
+		encoder := json.NewEncoder(os.Stdout)
+		for _, record := range records {
+			err := encoder.Encode(record)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case "csv", "tsv":
+		return writeTable(os.Stdout, s.output == "tsv", records)
+	}
+
+	for _, record := range records {
+		err := printText(record)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The indexes and names of a record's fields, from their json tags
+func recordFields(recordType reflect.Type) ([]int, []string) {
+	indexes, names := []int{}, []string{}
+	for i := range recordType.NumField() {
+		field := recordType.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if len(name) == 0 {
+			name = field.Name
+		}
+		indexes = append(indexes, i)
+		names = append(names, name)
+	}
+	return indexes, names
+}
+
+// A field as it appears in a CSV or TSV cell. Strings and times are written
+// as they are in JSON but unquoted, null is empty, and lists and objects are
+// left as JSON.
+func formatCell(value reflect.Value) (string, error) {
+	data, err := json.Marshal(value.Interface())
+	if err != nil {
+		return "", err
+	}
+	if bytes.Equal(data, []byte("null")) {
+		return "", nil
+	}
+	if data[0] == '"' {
+		var text string
+		err = json.Unmarshal(data, &text)
+		return text, err
+	}
+	return string(data), nil
+}
+
+// Escape a TSV cell so that it stays on one line, as PostgreSQL's COPY does
+var tsvEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+// Write records as CSV, or TSV, with a header row of field names
+func writeTable[T any](w io.Writer, tabs bool, records []T) error {
+	recordType := reflect.TypeFor[T]()
+	if recordType.Kind() != reflect.Struct {
+		return fmt.Errorf("Cannot print %v as a table", recordType)
+	}
+
+	indexes, names := recordFields(recordType)
+	rows := [][]string{names}
+	for _, record := range records {
+		value := reflect.ValueOf(record)
+		row := []string{}
+		for _, i := range indexes {
+			cell, err := formatCell(value.Field(i))
+			if err != nil {
+				return err
+			}
+			row = append(row, cell)
+		}
+		rows = append(rows, row)
+	}
+
+	if tabs {
+		for _, row := range rows {
+			for i := range row {
+				row[i] = tsvEscaper.Replace(row[i])
+			}
+			_, err := fmt.Fprintf(w, "%v\n", strings.Join(row, "\t"))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	writer := csv.NewWriter(w)
+	err := writer.WriteAll(rows)
+	if err != nil {
+		return err
+	}
+	return writer.Error()
+}
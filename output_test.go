@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+type tableRecord struct {
+	Name    string     `json:"name"`
+	Count   int        `json:"count"`
+	Tags    []string   `json:"tags"`
+	When    *time.Time `json:"when"`
+	private string
+	Skipped string `json:"-"`
+}
+
+func TestWriteTable(t *testing.T) {
+	when := time.Date(2026, 1, 7, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		record tableRecord
+		csv    string
+		tsv    string
+	}{
+		{"plain", tableRecord{Name: "feed", Count: 3, When: &when},
+			"feed,3,,2026-01-07T12:00:00Z\n",
+			"feed\t3\t\t2026-01-07T12:00:00Z\n"},
+		{"comma", tableRecord{Name: "a, b"},
+			"\"a, b\",0,,\n",
+			"a, b\t0\t\t\n"},
+		{"quote", tableRecord{Name: `say "hi"`},
+			"\"say \"\"hi\"\"\",0,,\n",
+			"say \"hi\"\t0\t\t\n"},
+		{"newline", tableRecord{Name: "one\ntwo\r\n"},
+			"\"one\ntwo\r\n\",0,,\n",
+			"one\\ntwo\\r\\n\t0\t\t\n"},
+		{"tab and backslash", tableRecord{Name: "a\tb\\c"},
+			"a\tb\\c,0,,\n",
+			"a\\tb\\\\c\t0\t\t\n"},
+		{"list", tableRecord{Name: "x", Tags: []string{"a", "b,c"}},
+			"x,0,\"[\"\"a\"\",\"\"b,c\"\"]\",\n",
+			"x\t0\t[\"a\",\"b,c\"]\t\n"},
+		{"markup", tableRecord{Name: "<b>&</b>"},
+			"<b>&</b>,0,,\n",
+			"<b>&</b>\t0\t\t\n"},
+	}
+
+	for _, test := range tests {
+		for _, tabs := range []bool{false, true} {
+			header, want := "name,count,tags,when\n", test.csv
+			if tabs {
+				header, want = "name\tcount\ttags\twhen\n", test.tsv
+			}
+			t.Run(test.name, func(t *testing.T) {
+				var out bytes.Buffer
+				err := writeTable(&out, tabs, []tableRecord{test.record})
+				if err != nil {
+					t.Fatalf("writeTable: %v", err)
+				}
+				if got := out.String(); got != header+want {
+					t.Errorf("Table is %q, expected %q", got, header+want)
+				}
+			})
+		}
+	}
+}
+
+func TestWriteTableNotStruct(t *testing.T) {
+	var out bytes.Buffer
+	err := writeTable(&out, false, []string{"a"})
+	if err == nil {
+		t.Errorf("Expected an error for records that are not structs")
+	}
+}
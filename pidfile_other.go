@@ -0,0 +1,33 @@
+//go:build !unix
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Without flock the best we can do is refuse to start if the PID file is
+// already there. A crashed daemon will leave it behind.
+type pidFile struct {
+	path string
+}
+
+func lockPIDFile(path string) (*pidFile, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if errors.Is(err, os.ErrExist) {
+		return nil, fmt.Errorf("Another gator daemon may already be running, "+
+			"delete %v if it is not", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = fmt.Fprintf(file, "%v\n", os.Getpid())
+	return &pidFile{path: path}, errors.Join(err, file.Close())
+}
+
+func (p *pidFile) release() error {
+	return os.Remove(p.path)
+}
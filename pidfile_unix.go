@@ -0,0 +1,59 @@
+//go:build unix
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"syscall"
+)
+
+// A PID file that is locked for as long as the daemon runs, so a second
+// daemon can tell one is already running even if the first one crashed
+// without removing the file
+type pidFile struct {
+	path string
+	file *os.File
+}
+
+func lockPIDFile(path string) (*pidFile, error) {
+	// Refuse to follow a symlink, or the file it points to would be
+	// truncated
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|syscall.O_NOFOLLOW, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		pid, _ := io.ReadAll(file)
+		file.Close()
+		return nil, fmt.Errorf("Another gator daemon is already running (PID %v, %v)",
+			strings.TrimSpace(string(pid)), path)
+	}
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("Locking %v: %w", path, err)
+	}
+
+	err = file.Truncate(0)
+	if err == nil {
+		_, err = fmt.Fprintf(file, "%v\n", os.Getpid())
+	}
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("Writing %v: %w", path, err)
+	}
+
+	return &pidFile{path: path, file: file}, nil
+}
+
+func (p *pidFile) release() error {
+	// Remove the file while it is still locked, so another daemon cannot
+	// lock it in between and then have it removed from under it
+	err := os.Remove(p.path)
+	return errors.Join(err, p.file.Close())
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Tavis7/bootdev-gator/internal/database"
+)
+
+// How many feeds `refresh` fetches at once
+const refreshConcurrency = 8
+
+type refreshResult struct {
+	feed     database.Feed
+	scraped  scrapeResult
+	duration time.Duration
+	err      error
+}
+
+// Work out which feeds `refresh` should fetch. With no URLs, that is the
+// feeds the current user follows.
+func feedsToRefresh(s *state, urls []string, all, following bool) ([]database.Feed, error) {
+	if len(urls) == 0 && !all {
+		following = true
+	}
+
+	feeds, err := s.database.GetAllFeeds(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	if all {
+		return feeds, nil
+	}
+
+	wanted := map[string]bool{}
+	for _, feedURL := range urls {
+		found := false
+		for _, feed := range feeds {
+			if feed.Url == feedURL {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("No feed with URL '%v'", feedURL)
+		}
+		wanted[feedURL] = true
+	}
+
+	if following {
+		user, err := s.database.GetUser(context.Background(), s.config.Current_user_name)
+		if err != nil {
+			return nil, err
+		}
+		followed, err := s.database.GetFollowedFeeds(context.Background(), user.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, feed := range followed {
+			wanted[feed.Url] = true
+		}
+	}
+
+	result := []database.Feed{}
+	for _, feed := range feeds {
+		if wanted[feed.Url] {
+			result = append(result, feed)
+		}
+	}
+	return result, nil
+}
+
+// Scrape feeds straight away, several at a time, instead of waiting for agg
+func handlerRefresh(s *state, cmd command) error {
+	feeds, err := feedsToRefresh(s, cmd.args,
+		cmd.boolFlag("all"), cmd.boolFlag("following"))
+	if err != nil {
+		return err
+	}
+	if len(feeds) == 0 {
+		fmt.Printf("No feeds to refresh\n")
+		return nil
+	}
+
+	jobs := make(chan database.Feed)
+	results := make(chan refreshResult)
+	wg := sync.WaitGroup{}
+	for i := 0; i < min(refreshConcurrency, len(feeds)); i++ {
+		wg.Go(func() {
+			for feed := range jobs {
+				start := time.Now()
+				logger := feedLogger(slog.Default(), feed.ID, feed.Url)
+				scraped, err := scrapeFeed(s, logger, feed, start.UTC())
+				results <- refreshResult{
+					feed:     feed,
+					scraped:  scraped,
+					duration: time.Since(start),
+					err:      err,
+				}
+			}
+		})
+	}
+	go func() {
+		for _, feed := range feeds {
+			jobs <- feed
+		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+
+	// Results are collected here so that progress lines do not interleave
+	done := []refreshResult{}
+	for result := range results {
+		done = append(done, result)
+		status := fmt.Sprintf("%v items, %v new", result.scraped.items, result.scraped.newItems)
+		if result.err != nil {
+			status = fmt.Sprintf("failed: %v", result.err)
+		}
+		fmt.Printf(`[%v/%v] "%v": %v`+"\n", len(done), len(feeds), result.feed.Name, status)
+	}
+
+	// Print the table in the same order as the feeds were listed
+	failed := 0
+	fmt.Printf("\n")
+	table := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(table, "FEED\tSTATUS\tITEMS\tNEW\tTIME\tWARNINGS\n")
+	for _, feed := range feeds {
+		for _, result := range done {
+			if result.feed.ID != feed.ID {
+				continue
+			}
+			status := "ok"
+			if result.err != nil {
+				status = "failed"
+				failed++
+			}
+			fmt.Fprintf(table, "%v\t%v\t%v\t%v\t%v\t%v\n",
+				feed.Name, status, result.scraped.items, result.scraped.newItems,
+				result.duration.Round(time.Millisecond), len(result.scraped.warnings))
+		}
+	}
+	err = table.Flush()
+	if err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%v of %v feeds failed to refresh", failed, len(feeds))
+	}
+
+	return nil
+}
@@ -1,68 +1,177 @@
 package main
 
 import (
-	"context"
+	"bytes"
+	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"fmt"
-	"html"
-	"io"
-	"net/http"
+	"strings"
 )
 
 type RSSFeed struct {
+	// rss, atom or json
+	format string
+	// Problems with the feed that did not stop it being parsed
+	warnings []string
+
 	Channel struct {
-		Title       string    `xml:"title"`
-		Link        string    `xml:"link"`
-		Description string    `xml:"description"`
-		Item        []RSSItem `xml:"item"`
+		Title string `xml:"title"`
+		// Must come before Link so that <atom:link> does not overwrite it
+		AtomLink    []AtomLink `xml:"http://www.w3.org/2005/Atom link"`
+		Link        string     `xml:"link"`
+		Description string     `xml:"description"`
+		Language    string     `xml:"language"`
+		// Must come before Image so that <itunes:image> does not overwrite it
+		ITunesImage struct {
+			Href string `xml:"href,attr"`
+		} `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd image"`
+		Image struct {
+			URL string `xml:"url"`
+		} `xml:"image"`
+		// Polling hints
+		TTL             string    `xml:"ttl"`
+		SkipHours       []string  `xml:"skipHours>hour"`
+		SkipDays        []string  `xml:"skipDays>day"`
+		UpdatePeriod    string    `xml:"http://purl.org/rss/1.0/modules/syndication/ updatePeriod"`
+		UpdateFrequency string    `xml:"http://purl.org/rss/1.0/modules/syndication/ updateFrequency"`
+		Item            []RSSItem `xml:"item"`
 	} `xml:"channel"`
 }
 
 type RSSItem struct {
-	Title       string `xml:"title"`
-	Link        string `xml:"link"`
-	Description string `xml:"description"`
-	PubDate     string `xml:"pubDate"`
+	Title          string            `xml:"title"`
+	Link           string            `xml:"link"`
+	Description    string            `xml:"description"`
+	PubDate        string            `xml:"pubDate"`
+	GUID           string            `xml:"guid"`
+	Enclosure      []RSSEnclosure    `xml:"enclosure"`
+	MediaContent   []RSSMediaContent `xml:"http://search.yahoo.com/mrss/ content"`
+	MediaGroup     []RSSMediaContent `xml:"http://search.yahoo.com/mrss/ group>content"`
+	ITunesDuration string            `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd duration"`
 }
 
-func fetchFeed(ctx context.Context, feedURL string) (RSSFeed, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", feedURL, nil)
-	if err != nil {
-		return RSSFeed{}, err
+// The URL of the image or icon representing a feed
+func (f RSSFeed) imageURL() string {
+	if len(strings.TrimSpace(f.Channel.Image.URL)) > 0 {
+		return strings.TrimSpace(f.Channel.Image.URL)
 	}
+	return strings.TrimSpace(f.Channel.ITunesImage.Href)
+}
 
-	req.Header.Set("User-Agent", "gator")
+type RSSEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length string `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
 
-	res, err := http.DefaultClient.Do(req)
+type RSSMediaContent struct {
+	URL      string `xml:"url,attr"`
+	FileSize string `xml:"fileSize,attr"`
+	Type     string `xml:"type,attr"`
+	Duration string `xml:"duration,attr"`
+}
+
+// Find the name of the root element, to tell RSS and Atom feeds apart
+func feedFormat(data []byte, lenient bool) (string, error) {
+	decoder := newXMLDecoder(data, lenient)
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return "", fmt.Errorf("Finding root element: %w", err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "rss", "RDF":
+			return "rss", nil
+		case "feed":
+			return "atom", nil
+		}
+		return "", fmt.Errorf("Unknown feed format: <%v>", start.Name.Local)
+	}
+}
+
+func parseXMLFeed(data []byte, lenient bool) (RSSFeed, error) {
+	format, err := feedFormat(data, lenient)
 	if err != nil {
 		return RSSFeed{}, err
 	}
-	if res.StatusCode >= 300 {
-		return RSSFeed{}, fmt.Errorf("Unexpected status code: %v", res.StatusCode)
-	}
 
-	defer res.Body.Close()
+	if format == "atom" {
+		atom := AtomFeed{}
+		err = newXMLDecoder(data, lenient).Decode(&atom)
+		if err != nil {
+			return RSSFeed{}, err
+		}
+		result := atom.toRSS()
+		result.format = format
+		return result, nil
+	}
 
-	bodyText, err := io.ReadAll(res.Body)
+	result := RSSFeed{}
+	err = newXMLDecoder(data, lenient).Decode(&result)
 	if err != nil {
 		return RSSFeed{}, err
 	}
+	result.format = format
+	return result, nil
+}
 
-	result := RSSFeed{}
-
-	err = xml.Unmarshal(bodyText, &result)
+// Parse an RSS, Atom or JSON feed. contentType is the HTTP Content-Type
+// header, if there is one, which is used to work out the charset.
+func parseFeed(data []byte, contentType string) (RSSFeed, error) {
+	data, err := decodeCharset(data, contentType)
 	if err != nil {
 		return RSSFeed{}, err
 	}
 
-	result.Channel.Title = html.UnescapeString(result.Channel.Title)
-	result.Channel.Description = html.UnescapeString(result.Channel.Description)
+	result := RSSFeed{}
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		jsonFeed := JSONFeed{}
+		err = json.Unmarshal(data, &jsonFeed)
+		if err != nil {
+			return RSSFeed{}, err
+		}
+		result, err = jsonFeed.toRSS()
+		if err != nil {
+			return RSSFeed{}, err
+		}
+		result.format = "json"
+	} else {
+		result, err = parseXMLFeed(data, false)
+		// Plenty of feeds are not quite XML, try again more forgivingly
+		syntaxErr := &xml.SyntaxError{}
+		if errors.As(err, &syntaxErr) {
+			result, err = parseXMLFeed(repairXML(data), true)
+			if err != nil {
+				return RSSFeed{}, err
+			}
+			result.warnings = append(result.warnings,
+				fmt.Sprintf("Feed is not well-formed XML (%v), parsed it leniently", syntaxErr))
+		}
+		if err != nil {
+			return RSSFeed{}, err
+		}
+	}
+
+	// Titles are plain text, though RSS titles often have entities in
+	// them. Atom and JSON Feed titles are already converted. Descriptions
+	// are kept as HTML but with anything unsafe removed.
+	if result.format == "rss" {
+		result.Channel.Title = unescapeLine(result.Channel.Title)
+	}
+	result.Channel.Description = renderPlainLine(result.Channel.Description)
 
 	for i := 0; i < len(result.Channel.Item); i++ {
-		result.Channel.Item[i].Title =
-			html.UnescapeString(result.Channel.Item[i].Title)
+		if result.format == "rss" {
+			result.Channel.Item[i].Title = unescapeLine(result.Channel.Item[i].Title)
+		}
 		result.Channel.Item[i].Description =
-			html.UnescapeString(result.Channel.Item[i].Description)
+			sanitizeHTML(result.Channel.Item[i].Description)
 	}
 
 	return result, nil
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// The fixtures and what they should parse to, as listed in
+// testdata/feeds/README.md
+func TestParseFeedFixtures(t *testing.T) {
+	tests := []struct {
+		file    string
+		title   string
+		warning bool
+	}{
+		{"well-formed.xml", "Plain title", false},
+		{"html-entities.xml", "Café — news today", true},
+		{"unescaped-ampersand.xml", "Salt & pepper", true},
+		{"control-chars.xml", "Verticaltab", true},
+		{"bom-utf8.xml", "BOM first", false},
+		{"bom-utf8-latin1-declared.xml", "Grüße aus Köln", false},
+		{"bom-utf16le.xml", "UTF-16 über", false},
+		{"bom-utf16be.xml", "UTF-16 über", false},
+		{"latin1-declared.xml", "Grüße aus Köln", false},
+		{"latin1-declared-entities.xml", "Grüße aus Köln", true},
+		{"latin1-undeclared.xml", "Grüße", true},
+		{"atom-entities.xml", "Entry—one", true},
+		{"json-bom.json", "First", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.file, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", "feeds", test.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			feed, err := parseFeed(data, "")
+			if err != nil {
+				t.Fatalf("parseFeed: %v", err)
+			}
+			if len(feed.Channel.Item) == 0 {
+				t.Fatalf("No items")
+			}
+			if title := feed.Channel.Item[0].Title; title != test.title {
+				t.Errorf("Title is %q, expected %q", title, test.title)
+			}
+			if warned := len(feed.warnings) > 0; warned != test.warning {
+				t.Errorf("Warning is %v, expected %v: %q", warned, test.warning, feed.warnings)
+			}
+		})
+	}
+}
+
+func TestParseJSONFeed(t *testing.T) {
+	tests := []struct {
+		name  string
+		item  string
+		title string
+		guid  string
+	}{
+		{"string id", `{"id": "abc", "title": "Plain"}`, "Plain", "abc"},
+		{"escaped id", `{"id": "https://example.com\/a \"b\"", "title": "x"}`,
+			"x", `https://example.com/a "b"`},
+		{"number id", `{"id": 12345678901234567890, "title": "x"}`, "x", "12345678901234567890"},
+		{"no id", `{"title": "x"}`, "x", ""},
+		{"entity in title", `{"id": "1", "title": "AT&amp;T"}`, "AT&amp;T", "1"},
+		{"tag in title", `{"id": "1", "title": "Why <script> blocks"}`, "Why <script> blocks", "1"},
+		{"whitespace in title", `{"id": "1", "title": " One\n two\u001b "}`, "One two", "1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data := `{"version": "https://jsonfeed.org/version/1.1", "title": "Feed", "items": [` +
+				test.item + `]}`
+			feed, err := parseFeed([]byte(data), "")
+			if err != nil {
+				t.Fatalf("parseFeed: %v", err)
+			}
+			item := feed.Channel.Item[0]
+			if item.Title != test.title {
+				t.Errorf("Title is %q, expected %q", item.Title, test.title)
+			}
+			if item.GUID != test.guid {
+				t.Errorf("GUID is %q, expected %q", item.GUID, test.guid)
+			}
+		})
+	}
+}
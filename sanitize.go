@@ -0,0 +1,453 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Elements that are removed along with everything inside them
+var droppedElements = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Noscript: true,
+	atom.Iframe:   true,
+	atom.Frame:    true,
+	atom.Frameset: true,
+	atom.Object:   true,
+	atom.Embed:    true,
+	atom.Applet:   true,
+	atom.Form:     true,
+	atom.Input:    true,
+	atom.Button:   true,
+	atom.Select:   true,
+	atom.Textarea: true,
+	atom.Link:     true,
+	atom.Meta:     true,
+	atom.Base:     true,
+	atom.Head:     true,
+	atom.Title:    true,
+	atom.Template: true,
+	atom.Svg:      true,
+	atom.Math:     true,
+}
+
+// Elements that are kept, and the attributes allowed on each of them.
+// Anything not listed here is unwrapped, keeping only its children.
+var allowedElements = map[atom.Atom][]string{
+	atom.A:          {"href", "title"},
+	atom.Abbr:       {"title"},
+	atom.B:          {},
+	atom.Blockquote: {"cite"},
+	atom.Br:         {},
+	atom.Code:       {},
+	atom.Dd:         {},
+	atom.Del:        {},
+	atom.Div:        {},
+	atom.Dl:         {},
+	atom.Dt:         {},
+	atom.Em:         {},
+	atom.Figcaption: {},
+	atom.Figure:     {},
+	atom.H1:         {},
+	atom.H2:         {},
+	atom.H3:         {},
+	atom.H4:         {},
+	atom.H5:         {},
+	atom.H6:         {},
+	atom.Hr:         {},
+	atom.I:          {},
+	atom.Img:        {"src", "alt", "title", "width", "height"},
+	atom.Ins:        {},
+	atom.Li:         {},
+	atom.Ol:         {},
+	atom.P:          {},
+	atom.Pre:        {},
+	atom.Q:          {"cite"},
+	atom.S:          {},
+	atom.Small:      {},
+	atom.Span:       {},
+	atom.Strong:     {},
+	atom.Sub:        {},
+	atom.Sup:        {},
+	atom.Table:      {},
+	atom.Tbody:      {},
+	atom.Td:         {"colspan", "rowspan"},
+	atom.Tfoot:      {},
+	atom.Th:         {"colspan", "rowspan"},
+	atom.Thead:      {},
+	atom.Tr:         {},
+	atom.U:          {},
+	atom.Ul:         {},
+}
+
+// Hosts that only serve tracking pixels and analytics beacons
+var trackerHosts = []string{
+	"feeds.feedburner.com",
+	"feedproxy.google.com",
+	"pixel.wp.com",
+	"stats.wordpress.com",
+	"www.google-analytics.com",
+	"pixel.quantserve.com",
+	"sb.scorecardresearch.com",
+	"ad.doubleclick.net",
+}
+
+var blockElements = map[atom.Atom]bool{
+	atom.Address:    true,
+	atom.Article:    true,
+	atom.Aside:      true,
+	atom.Blockquote: true,
+	atom.Dd:         true,
+	atom.Div:        true,
+	atom.Dl:         true,
+	atom.Dt:         true,
+	atom.Figcaption: true,
+	atom.Figure:     true,
+	atom.Footer:     true,
+	atom.H1:         true,
+	atom.H2:         true,
+	atom.H3:         true,
+	atom.H4:         true,
+	atom.H5:         true,
+	atom.H6:         true,
+	atom.Header:     true,
+	atom.Hr:         true,
+	atom.Li:         true,
+	atom.Ol:         true,
+	atom.P:          true,
+	atom.Pre:        true,
+	atom.Section:    true,
+	atom.Table:      true,
+	atom.Tr:         true,
+	atom.Ul:         true,
+}
+
+// Control characters, other than newlines and tabs, are removed from
+// everything saved or printed, since feed content ends up on a terminal where
+// they could be escape sequences that change colours, the window title, etc.
+func isControlChar(r rune) bool {
+	return (r < 0x20 && r != '\n' && r != '\t') || (r >= 0x7f && r <= 0x9f)
+}
+
+func stripControlChars(s string) string {
+	return strings.Map(func(r rune) rune {
+		if isControlChar(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+func parseHTMLFragment(s string) ([]*html.Node, error) {
+	return html.ParseFragment(strings.NewReader(s), &html.Node{
+		Type:     html.ElementNode,
+		Data:     "body",
+		DataAtom: atom.Body,
+	})
+}
+
+// Only allow links to web pages and mail addresses, relative links are kept
+func isSafeURL(rawURL string) bool {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "", "http", "https", "mailto":
+		return true
+	}
+	return false
+}
+
+func isTracker(n *html.Node) bool {
+	if n.DataAtom != atom.Img {
+		return false
+	}
+
+	width, height := "", ""
+	for _, attr := range n.Attr {
+		switch attr.Key {
+		case "width":
+			width = strings.TrimSpace(attr.Val)
+		case "height":
+			height = strings.TrimSpace(attr.Val)
+		case "src":
+			u, err := url.Parse(attr.Val)
+			if err != nil {
+				return true
+			}
+			for _, host := range trackerHosts {
+				if strings.EqualFold(u.Hostname(), host) {
+					return true
+				}
+			}
+		}
+	}
+
+	tiny := func(s string) bool {
+		return s == "0" || s == "1" || s == "0px" || s == "1px"
+	}
+	return tiny(width) && tiny(height)
+}
+
+func sanitizeNode(n *html.Node) []*html.Node {
+	switch n.Type {
+	case html.TextNode:
+		return []*html.Node{{Type: html.TextNode, Data: stripControlChars(n.Data)}}
+	case html.ElementNode:
+	default:
+		// Comments, doctypes, etc.
+		return nil
+	}
+
+	if droppedElements[n.DataAtom] || isTracker(n) {
+		return nil
+	}
+
+	children := []*html.Node{}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		children = append(children, sanitizeNode(c)...)
+	}
+
+	allowedAttrs, ok := allowedElements[n.DataAtom]
+	if !ok {
+		return children
+	}
+
+	result := &html.Node{
+		Type:     html.ElementNode,
+		Data:     n.Data,
+		DataAtom: n.DataAtom,
+	}
+	for _, attr := range n.Attr {
+		if attr.Namespace != "" {
+			continue
+		}
+		allowed := false
+		for _, key := range allowedAttrs {
+			if attr.Key == key {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			continue
+		}
+		if (attr.Key == "href" || attr.Key == "src" || attr.Key == "cite") &&
+			!isSafeURL(attr.Val) {
+			continue
+		}
+		result.Attr = append(result.Attr,
+			html.Attribute{Key: attr.Key, Val: stripControlChars(attr.Val)})
+	}
+	if n.DataAtom == atom.A {
+		result.Attr = append(result.Attr,
+			html.Attribute{Key: "rel", Val: "nofollow noopener noreferrer"})
+	}
+
+	for _, c := range children {
+		result.AppendChild(c)
+	}
+
+	return []*html.Node{result}
+}
+
+// Strip scripts, styles, trackers, event handlers and anything else that
+// is not plain formatting from an HTML fragment, returning safe HTML
+func sanitizeHTML(s string) string {
+	nodes, err := parseHTMLFragment(s)
+	if err != nil {
+		return html.EscapeString(stripControlChars(s))
+	}
+
+	buf := bytes.Buffer{}
+	for _, n := range nodes {
+		for _, clean := range sanitizeNode(n) {
+			err := html.Render(&buf, clean)
+			if err != nil {
+				return html.EscapeString(stripControlChars(s))
+			}
+		}
+	}
+
+	return strings.TrimSpace(buf.String())
+}
+
+type textRenderer struct {
+	blocks    []string
+	current   strings.Builder
+	links     []string
+	prefix    string
+	preformat bool
+	footnotes bool
+}
+
+func (r *textRenderer) flush() {
+	text := r.current.String()
+	r.current.Reset()
+	if !r.preformat {
+		text = strings.Join(strings.Fields(text), " ")
+	}
+	if len(strings.TrimSpace(text)) == 0 {
+		return
+	}
+	r.blocks = append(r.blocks, r.prefix+text)
+}
+
+func (r *textRenderer) walk(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		r.current.WriteString(stripControlChars(n.Data))
+		return
+	case html.ElementNode, html.DocumentNode:
+	default:
+		return
+	}
+
+	if droppedElements[n.DataAtom] || isTracker(n) {
+		return
+	}
+
+	block := blockElements[n.DataAtom]
+	if block {
+		r.flush()
+	}
+
+	oldPrefix := r.prefix
+	oldPreformat := r.preformat
+	switch n.DataAtom {
+	case atom.Br:
+		r.flush()
+	case atom.Li:
+		r.prefix = "- "
+	case atom.Blockquote:
+		r.prefix = "> "
+	case atom.Pre:
+		r.preformat = true
+	case atom.Img:
+		for _, attr := range n.Attr {
+			if attr.Key == "alt" && len(strings.TrimSpace(attr.Val)) > 0 {
+				fmt.Fprintf(&r.current, "[image: %v]",
+					strings.TrimSpace(stripControlChars(attr.Val)))
+			}
+		}
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		r.walk(c)
+	}
+
+	if n.DataAtom == atom.A && r.footnotes {
+		for _, attr := range n.Attr {
+			if attr.Key == "href" && isSafeURL(attr.Val) && len(attr.Val) > 0 {
+				r.links = append(r.links, stripControlChars(attr.Val))
+				fmt.Fprintf(&r.current, " [%v]", len(r.links))
+				break
+			}
+		}
+	}
+
+	if block {
+		r.flush()
+	}
+	r.prefix = oldPrefix
+	r.preformat = oldPreformat
+}
+
+// Wrap text at word boundaries so that no line is longer than width,
+// unless a single word is longer than width. A width <= 0 disables wrapping.
+func wrapText(text string, width int, indent string) []string {
+	if width <= 0 {
+		return []string{indent + text}
+	}
+
+	lines := []string{}
+	line := ""
+	for _, word := range strings.Fields(text) {
+		if len(line) > 0 && len(indent)+len(line)+1+len(word) > width {
+			lines = append(lines, indent+line)
+			line = ""
+		}
+		if len(line) > 0 {
+			line += " "
+		}
+		line += word
+	}
+	if len(line) > 0 {
+		lines = append(lines, indent+line)
+	}
+	return lines
+}
+
+// Convert an HTML fragment to plain text wrapped at width columns, with
+// every line prefixed by indent. Links are replaced with numbered
+// references which are listed as footnotes after the text.
+func renderPlainText(s string, width int, indent string) string {
+	nodes, err := parseHTMLFragment(s)
+	if err != nil {
+		return strings.Join(wrapText(stripControlChars(s), width, indent), "\n")
+	}
+
+	r := textRenderer{footnotes: true}
+	for _, n := range nodes {
+		r.walk(n)
+	}
+	r.flush()
+
+	lines := []string{}
+	for i, block := range r.blocks {
+		listItem := strings.HasPrefix(block, "- ")
+		if i > 0 && !(listItem && strings.HasPrefix(r.blocks[i-1], "- ")) {
+			lines = append(lines, "")
+		}
+		if strings.Contains(block, "\n") {
+			for _, l := range strings.Split(block, "\n") {
+				lines = append(lines, indent+l)
+			}
+			continue
+		}
+		lines = append(lines, wrapText(block, width, indent)...)
+	}
+
+	if len(r.links) > 0 {
+		lines = append(lines, "")
+		for i, link := range r.links {
+			lines = append(lines, fmt.Sprintf("%v[%v] %v", indent, i+1, link))
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Convert plain text to a single line, without control characters
+func cleanLine(s string) string {
+	return strings.Join(strings.Fields(stripControlChars(s)), " ")
+}
+
+// Convert text that may have HTML entities in it to a single line. Entities
+// can stand for control characters too, so they are removed afterwards.
+func unescapeLine(s string) string {
+	return cleanLine(html.UnescapeString(s))
+}
+
+// Convert an HTML fragment such as an Atom title to a single line of plain
+// text
+func renderPlainLine(s string) string {
+	nodes, err := parseHTMLFragment(s)
+	if err != nil {
+		return cleanLine(s)
+	}
+
+	r := textRenderer{}
+	for _, n := range nodes {
+		r.walk(n)
+	}
+	r.flush()
+
+	return cleanLine(strings.Join(r.blocks, " "))
+}
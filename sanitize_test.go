@@ -0,0 +1,92 @@
+package main
+
+import "testing"
+
+func TestSanitizeHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"plain", "<p>Hello <b>world</b></p>", "<p>Hello <b>world</b></p>"},
+		{"script", "<p>Hi<script>alert(1)</script></p>", "<p>Hi</p>"},
+		{"style", "<style>p{}</style><p>Hi</p>", "<p>Hi</p>"},
+		{"event handler", `<a href="https://example.com/" onclick="steal()">x</a>`,
+			`<a href="https://example.com/" rel="nofollow noopener noreferrer">x</a>`},
+		{"javascript href", `<a href="javascript:alert(1)">x</a>`,
+			`<a rel="nofollow noopener noreferrer">x</a>`},
+		{"image onerror", `<img src="https://example.com/a.png" onerror="steal()" alt="A">`,
+			`<img src="https://example.com/a.png" alt="A"/>`},
+		{"tracker pixel", `<p>Hi<img src="https://example.com/p.gif" width="1" height="1"></p>`,
+			"<p>Hi</p>"},
+		{"tracker host", `<p>Hi<img src="https://pixel.wp.com/g.gif"></p>`, "<p>Hi</p>"},
+		{"unknown element", "<section><p>Hi</p></section>", "<p>Hi</p>"},
+		{"escape sequence", "<p>a\x1b[31mRED</p>", "<p>a[31mRED</p>"},
+		{"escaped escape sequence", "<p>a&#27;[31mRED</p>", "<p>a[31mRED</p>"},
+		{"title sequence", "<p>\x1b]0;pwned\a</p>", "<p>]0;pwned</p>"},
+		{"C1 control", "<p>a\u009bb</p>", "<p>ab</p>"},
+		{"control in attribute", "<img src=\"https://example.com/a.png\" alt=\"a\x1bb\">",
+			`<img src="https://example.com/a.png" alt="ab"/>`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := sanitizeHTML(test.in); got != test.want {
+				t.Errorf("sanitizeHTML(%q) = %q, expected %q", test.in, got, test.want)
+			}
+		})
+	}
+}
+
+func TestRenderPlainText(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"paragraphs", "<p>One</p><p>Two</p>", "One\n\nTwo"},
+		{"list", "<ul><li>One</li><li>Two</li></ul>", "- One\n- Two"},
+		{"link", `<p>See <a href="https://example.com/">this</a></p>`,
+			"See this [1]\n\n[1] https://example.com/"},
+		{"javascript link", `<p><a href="javascript:alert(1)">this</a></p>`, "this"},
+		{"script", "<p>Hi</p><script>alert(1)</script>", "Hi"},
+		{"image", `<img src="https://example.com/a.png" alt="A cat">`, "[image: A cat]"},
+		{"escape sequence", "<p>a\x1b[31mRED\x1b]0;pwned\a</p>", "a[31mRED]0;pwned"},
+		{"control in link", "<a href=\"https://example.com/\x1b[2J\">x</a>", "x"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := renderPlainText(test.in, 0, ""); got != test.want {
+				t.Errorf("renderPlainText(%q) = %q, expected %q", test.in, got, test.want)
+			}
+		})
+	}
+}
+
+func TestPlainLines(t *testing.T) {
+	tests := []struct {
+		name   string
+		render func(string) string
+		in     string
+		want   string
+	}{
+		{"entities", unescapeLine, "Caf&eacute; &amp; bar", "Café & bar"},
+		{"tag as text", unescapeLine, "Why the <script> tag blocks rendering",
+			"Why the <script> tag blocks rendering"},
+		{"whitespace", unescapeLine, "  One\n\ttwo  ", "One two"},
+		{"escaped escape sequence", unescapeLine, "a&#27;[31mRED", "a[31mRED"},
+		{"bell", unescapeLine, "a\ab", "ab"},
+		{"html", renderPlainLine, "A <b>bold</b> <i>move</i>", "A bold move"},
+		{"html escape sequence", renderPlainLine, "a&#27;[31m<b>RED</b>", "a[31mRED"},
+		{"clean", cleanLine, "a\x1b[31m \u0085 b", "a[31m b"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.render(test.in); got != test.want {
+				t.Errorf("Got %q from %q, expected %q", got, test.in, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Tavis7/bootdev-gator/internal/database"
+)
+
+// Publishers sometimes ask for absurdly long intervals, never wait longer
+// than this between fetches
+const maxHintInterval = 7 * 24 * time.Hour
+
+// What the publisher has told us about how often the feed should be fetched
+type pollingHints struct {
+	// Minimum time between fetches, from <ttl>, sy:updatePeriod or
+	// Cache-Control
+	interval time.Duration
+	// Hours (0-23, UTC) and days during which the feed should not be fetched
+	skipHours map[int]bool
+	skipDays  map[time.Weekday]bool
+	// Don't fetch again before this time, from Retry-After
+	retryAfter time.Time
+}
+
+var syndicationPeriods = map[string]time.Duration{
+	"hourly":  time.Hour,
+	"daily":   24 * time.Hour,
+	"weekly":  7 * 24 * time.Hour,
+	"monthly": 30 * 24 * time.Hour,
+	"yearly":  365 * 24 * time.Hour,
+}
+
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// Parse the max-age directive of a Cache-Control header
+func cacheMaxAge(header string) time.Duration {
+	for _, directive := range strings.Split(header, ",") {
+		key, value, found := strings.Cut(strings.TrimSpace(directive), "=")
+		if !found || strings.ToLower(key) != "max-age" {
+			continue
+		}
+		seconds, err := strconv.Atoi(strings.Trim(value, `"`))
+		if err == nil && seconds > 0 {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+	return 0
+}
+
+// Parse a Retry-After header, which is either a number of seconds or a date
+func retryAfter(header string, now time.Time) time.Time {
+	header = strings.TrimSpace(header)
+	if len(header) == 0 {
+		return time.Time{}
+	}
+	seconds, err := strconv.Atoi(header)
+	if err == nil {
+		return now.Add(time.Duration(seconds) * time.Second)
+	}
+	date, err := http.ParseTime(header)
+	if err == nil {
+		return date.UTC()
+	}
+	return time.Time{}
+}
+
+func feedPollingHints(feed RSSFeed, res *http.Response, now time.Time) pollingHints {
+	hints := pollingHints{
+		skipHours: map[int]bool{},
+		skipDays:  map[time.Weekday]bool{},
+	}
+
+	channel := feed.Channel
+	ttl, err := strconv.Atoi(strings.TrimSpace(channel.TTL))
+	if err == nil && ttl > 0 {
+		hints.interval = max(hints.interval, time.Duration(ttl)*time.Minute)
+	}
+
+	period, ok := syndicationPeriods[strings.ToLower(strings.TrimSpace(channel.UpdatePeriod))]
+	if ok {
+		frequency, err := strconv.Atoi(strings.TrimSpace(channel.UpdateFrequency))
+		if err != nil || frequency < 1 {
+			frequency = 1
+		}
+		hints.interval = max(hints.interval, period/time.Duration(frequency))
+	}
+
+	for _, hour := range channel.SkipHours {
+		h, err := strconv.Atoi(strings.TrimSpace(hour))
+		if err == nil && h >= 0 && h <= 24 {
+			// Some publishers count hours from 1 to 24
+			hints.skipHours[h%24] = true
+		}
+	}
+	for _, day := range channel.SkipDays {
+		d, ok := weekdays[strings.ToLower(strings.TrimSpace(day))]
+		if ok {
+			hints.skipDays[d] = true
+		}
+	}
+
+	if res != nil {
+		hints.interval = max(hints.interval, cacheMaxAge(res.Header.Get("Cache-Control")))
+		if res.StatusCode == http.StatusTooManyRequests ||
+			res.StatusCode == http.StatusServiceUnavailable {
+			hints.retryAfter = retryAfter(res.Header.Get("Retry-After"), now)
+		}
+	}
+
+	hints.interval = min(hints.interval, maxHintInterval)
+
+	return hints
+}
+
+// Work out when a feed should next be fetched, given when it was last fetched
+func nextFetchTime(now time.Time, hints pollingHints) time.Time {
+	next := now.Add(hints.interval)
+	if hints.retryAfter.After(next) {
+		next = hints.retryAfter
+	}
+
+	// skipHours and skipDays are in GMT. Give up after a week in case every
+	// hour is skipped.
+	next = next.UTC()
+	for i := 0; i < 7*24; i++ {
+		if !hints.skipHours[next.Hour()] && !hints.skipDays[next.Weekday()] {
+			break
+		}
+		next = next.Truncate(time.Hour).Add(time.Hour)
+	}
+
+	return next
+}
+
+// How many of a feed's most recent posts are used to estimate how often it
+// posts
+const recentPostsForInterval = 10
+
+// Estimate how often a feed should be fetched from how often it has posted
+// recently. Feeds are fetched twice as often as they post, within the
+// configured bounds.
+func adaptiveInterval(s *state, feedID uuid.UUID) (time.Duration, error) {
+	minInterval, maxInterval, err := s.config.PollIntervals()
+	if err != nil {
+		return 0, err
+	}
+
+	times, err := s.database.GetRecentPostTimes(context.Background(),
+		database.GetRecentPostTimesParams{
+			FeedID: feedID,
+			Limit:  recentPostsForInterval,
+		})
+	if err != nil {
+		return 0, err
+	}
+
+	return postingInterval(times, time.Now().UTC(), minInterval, maxInterval), nil
+}
+
+// Half the median gap between posts, or half the time since the last post if
+// that is longer, within minInterval and maxInterval. Times are newest first.
+func postingInterval(times []time.Time, now time.Time, minInterval, maxInterval time.Duration) time.Duration {
+	gaps := []time.Duration{}
+	for i := 1; i < len(times); i++ {
+		gap := times[i-1].Sub(times[i])
+		if gap > 0 {
+			gaps = append(gaps, gap)
+		}
+	}
+	if len(gaps) == 0 {
+		// Not enough history yet, keep checking often until there is
+		return minInterval
+	}
+
+	// The median is less thrown off than the mean by the odd burst of posts
+	slices.Sort(gaps)
+	median := gaps[len(gaps)/2]
+
+	// A feed that has gone quiet should be checked less often, even if it
+	// used to post frequently
+	sinceLast := now.Sub(times[0])
+	if sinceLast > median {
+		median = sinceLast
+	}
+
+	return min(max(median/2, minInterval), maxInterval)
+}
+
+// Work out when a feed should next be fetched and save it
+func scheduleFeed(s *state, feedID uuid.UUID, feed RSSFeed, res *http.Response, now time.Time) error {
+	interval, err := adaptiveInterval(s, feedID)
+	if err != nil {
+		return err
+	}
+
+	hints := feedPollingHints(feed, res, now)
+	hints.interval = max(hints.interval, interval)
+	next := nextFetchTime(now, hints)
+
+	_, err = s.database.SetFeedNextFetch(context.Background(),
+		database.SetFeedNextFetchParams{
+			NextFetchAt:         sql.NullTime{Time: next, Valid: true},
+			PollIntervalSeconds: sql.NullInt32{Int32: int32(interval.Seconds()), Valid: true},
+			ID:                  feedID,
+		})
+	if err != nil {
+		return fmt.Errorf("Scheduling next fetch: %w", err)
+	}
+
+	return nil
+}
+
+// Times are null if the feed has never been fetched
+type scheduleRecord struct {
+	Name                string     `json:"name"`
+	URL                 string     `json:"url"`
+	LastFetchedAt       *time.Time `json:"last_fetched_at"`
+	NextFetchAt         *time.Time `json:"next_fetch_at"`
+	PollIntervalSeconds *int32     `json:"poll_interval_seconds"`
+}
+
+func printFeedSchedule(s *state) error {
+	feeds, err := s.database.GetFeedSchedule(context.Background())
+	if err != nil {
+		return err
+	}
+
+	records := []scheduleRecord{}
+	for _, feed := range feeds {
+		record := scheduleRecord{Name: feed.Name, URL: feed.Url}
+		if feed.LastFetchedAt.Valid {
+			record.LastFetchedAt = &feed.LastFetchedAt.Time
+		}
+		if feed.NextFetchAt.Valid {
+			record.NextFetchAt = &feed.NextFetchAt.Time
+		}
+		if feed.PollIntervalSeconds.Valid {
+			record.PollIntervalSeconds = &feed.PollIntervalSeconds.Int32
+		}
+		records = append(records, record)
+	}
+
+	return printRecords(s, records, func(feed scheduleRecord) error {
+		last := "never"
+		if feed.LastFetchedAt != nil {
+			last = feed.LastFetchedAt.Format(time.DateTime)
+		}
+		next := "now"
+		if feed.NextFetchAt != nil {
+			next = feed.NextFetchAt.Format(time.DateTime)
+		}
+		interval := "unknown"
+		if feed.PollIntervalSeconds != nil {
+			interval = (time.Duration(*feed.PollIntervalSeconds) * time.Second).String()
+		}
+		fmt.Printf(`"%v": %v`+"\n    last fetched: %v, next fetch: %v, every %v\n",
+			feed.Name, feed.URL, last, next, interval)
+		return nil
+	})
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCacheMaxAge(t *testing.T) {
+	tests := []struct {
+		header string
+		want   time.Duration
+	}{
+		{"max-age=3600", time.Hour},
+		{"public, max-age=60", time.Minute},
+		{`MAX-AGE="120"`, 2 * time.Minute},
+		{"s-maxage=60, max-age=30", 30 * time.Second},
+		{"max-age=0", 0},
+		{"max-age=-5", 0},
+		{"max-age=soon", 0},
+		{"no-cache", 0},
+		{"", 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.header, func(t *testing.T) {
+			if got := cacheMaxAge(test.header); got != test.want {
+				t.Errorf("cacheMaxAge(%q) = %v, expected %v", test.header, got, test.want)
+			}
+		})
+	}
+}
+
+func TestNextFetchTime(t *testing.T) {
+	// A Wednesday
+	now := time.Date(2026, 1, 7, 10, 30, 0, 0, time.UTC)
+	allHours := map[int]bool{}
+	for h := range 24 {
+		allHours[h] = true
+	}
+
+	tests := []struct {
+		name  string
+		now   time.Time
+		hints pollingHints
+		want  time.Time
+	}{
+		{"interval", now, pollingHints{interval: time.Hour}, now.Add(time.Hour)},
+		{"local time", now.In(time.FixedZone("EST", -5*60*60)),
+			pollingHints{interval: time.Hour}, now.Add(time.Hour)},
+		{"retry after", now, pollingHints{interval: time.Hour, retryAfter: now.Add(3 * time.Hour)},
+			now.Add(3 * time.Hour)},
+		{"retry after passed", now, pollingHints{interval: time.Hour, retryAfter: now.Add(time.Minute)},
+			now.Add(time.Hour)},
+		{"skip hours", now, pollingHints{interval: time.Hour, skipHours: map[int]bool{11: true, 12: true}},
+			time.Date(2026, 1, 7, 13, 0, 0, 0, time.UTC)},
+		{"skip days", now, pollingHints{skipDays: map[time.Weekday]bool{time.Wednesday: true}},
+			time.Date(2026, 1, 8, 0, 0, 0, 0, time.UTC)},
+		{"every hour skipped", now, pollingHints{skipHours: allHours},
+			time.Date(2026, 1, 14, 10, 0, 0, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := nextFetchTime(test.now, test.hints)
+			if !got.Equal(test.want) || got.Location() != time.UTC {
+				t.Errorf("nextFetchTime is %v, expected %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPostingInterval(t *testing.T) {
+	now := time.Date(2026, 1, 7, 12, 0, 0, 0, time.UTC)
+	hoursAgo := func(hours ...int) []time.Time {
+		times := []time.Time{}
+		for _, h := range hours {
+			times = append(times, now.Add(-time.Duration(h)*time.Hour))
+		}
+		return times
+	}
+	minInterval, maxInterval := 30*time.Minute, 24*time.Hour
+
+	tests := []struct {
+		name  string
+		times []time.Time
+		want  time.Duration
+	}{
+		{"no posts", nil, minInterval},
+		{"one post", hoursAgo(1), minInterval},
+		{"same time", hoursAgo(1, 1), minInterval},
+		{"regular", hoursAgo(0, 4, 8, 12), 2 * time.Hour},
+		{"median", hoursAgo(0, 1, 2, 10, 30), 4 * time.Hour},
+		{"gone quiet", hoursAgo(10, 12, 14), 5 * time.Hour},
+		{"minimum", []time.Time{now, now.Add(-time.Minute), now.Add(-2 * time.Minute)},
+			minInterval},
+		{"maximum", hoursAgo(0, 100, 200), maxInterval},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := postingInterval(test.times, now, minInterval, maxInterval)
+			if got != test.want {
+				t.Errorf("postingInterval is %v, expected %v", got, test.want)
+			}
+		})
+	}
+}
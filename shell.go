@@ -0,0 +1,378 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"unicode"
+
+	"golang.org/x/term"
+)
+
+// Most recent lines kept in the shell's history file
+const shellHistorySize = 1000
+
+// Commands that only make sense inside the shell
+var shellBuiltins = []completion{
+	{value: "exit", description: "Leave the shell"},
+	{value: "user", description: "Act as another user until the shell exits, without logging in as them"},
+}
+
+// The global options that can be given on a line typed into the shell, for
+// that line only. Their defaults are empty so that they only apply if given.
+func shellLineFlags() []flagDef {
+	defs := []flagDef{}
+	for _, def := range globalFlags {
+		if def.name == "output" || def.name == "user" {
+			def.defaultValue = ""
+			defs = append(defs, def)
+		}
+	}
+	return defs
+}
+
+// Lines typed into the shell, saved to a file as they are added so they are
+// kept if the shell is killed
+type shellHistory struct {
+	// Oldest first
+	entries []string
+	file    *os.File
+}
+
+func openShellHistory(path string) (*shellHistory, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	entries := []string{}
+	for line := range strings.Lines(string(data)) {
+		line = strings.TrimRight(line, "\r\n")
+		if len(line) > 0 {
+			entries = append(entries, line)
+		}
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	if len(entries) > shellHistorySize {
+		// Rewrite the file without the oldest lines
+		entries = entries[len(entries)-shellHistorySize:]
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	file, err := os.OpenFile(path, flags, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if flags&os.O_TRUNC != 0 {
+		_, err = file.WriteString(strings.Join(entries, "\n") + "\n")
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+
+	return &shellHistory{entries: entries, file: file}, nil
+}
+
+func (h *shellHistory) Add(entry string) {
+	if len(strings.TrimSpace(entry)) == 0 ||
+		(len(h.entries) > 0 && h.entries[len(h.entries)-1] == entry) {
+		return
+	}
+	h.entries = append(h.entries, entry)
+	if len(h.entries) > shellHistorySize {
+		h.entries = h.entries[1:]
+	}
+	_, err := fmt.Fprintln(h.file, entry)
+	if err != nil {
+		slog.Warn("Saving shell history", "error", err)
+	}
+}
+
+func (h *shellHistory) Len() int {
+	return len(h.entries)
+}
+
+func (h *shellHistory) At(i int) string {
+	return h.entries[len(h.entries)-1-i]
+}
+
+func (h *shellHistory) Close() error {
+	return h.file.Close()
+}
+
+// Split a line typed into the shell into words. Single and double quotes
+// group words with spaces in them, and a backslash escapes the next
+// character.
+func splitShellLine(line string) ([]string, error) {
+	words := []string{}
+	word := strings.Builder{}
+	inWord := false
+	var quote rune
+	escaped := false
+	for _, r := range line {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inWord = true
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			word.WriteRune(r)
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case unicode.IsSpace(r):
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("Unterminated %c quote", quote)
+	}
+	if escaped {
+		return nil, fmt.Errorf("Nothing after \\ to escape")
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+type shell struct {
+	state    *state
+	terminal *term.Terminal
+}
+
+func (sh *shell) prompt() string {
+	if len(sh.state.config.Current_user_name) == 0 {
+		return "gator> "
+	}
+	return fmt.Sprintf("gator (%v)> ", sh.state.config.Current_user_name)
+}
+
+// Complete the word before the cursor when tab is pressed. If there is more
+// than one way to complete it, as much as they have in common is filled in,
+// and pressing tab again lists them.
+func (sh *shell) autoComplete(line string, pos int, key rune) (string, int, bool) {
+	if key != '\t' {
+		return "", 0, false
+	}
+
+	words, err := splitShellLine(line[:pos])
+	if err != nil {
+		return "", 0, false
+	}
+	if pos == 0 || unicode.IsSpace(rune(line[pos-1])) {
+		words = append(words, "")
+	}
+	current := words[len(words)-1]
+	// The word as typed, which may have been quoted
+	typed := line[pos-len(current) : pos]
+	if typed != current {
+		return "", 0, false
+	}
+
+	var completions []completion
+	if len(words) == 2 && words[0] == "user" {
+		completions, err = completeUsers(sh.state)
+	} else {
+		completions, err = completeWords(sh.state, words)
+		if len(words) == 1 {
+			completions = slices.Concat(shellBuiltins, completions)
+		}
+	}
+	if err != nil {
+		return "", 0, false
+	}
+
+	values := []string{}
+	for _, c := range completions {
+		if strings.HasPrefix(c.value, current) {
+			values = append(values, c.value)
+		}
+	}
+	if len(values) == 0 {
+		return "", 0, false
+	}
+
+	completed := values[0]
+	for _, value := range values[1:] {
+		for !strings.HasPrefix(value, completed) {
+			completed = completed[:len(completed)-1]
+		}
+	}
+	if len(values) == 1 {
+		completed += " "
+	} else if completed == current {
+		fmt.Fprintf(sh.terminal, "%v\n", strings.Join(values, "  "))
+		return "", 0, false
+	}
+
+	start := pos - len(current)
+	return line[:start] + completed + line[pos:], start + len(completed), true
+}
+
+// Run one line typed into the shell. It returns io.EOF when it is time to
+// leave.
+func (sh *shell) runLine(line string) error {
+	words, err := splitShellLine(line)
+	if err != nil || len(words) == 0 {
+		return err
+	}
+
+	options, words, err := parseFlags(shellLineFlags(), words, true)
+	if err != nil {
+		return err
+	}
+	if len(words) == 0 {
+		return fmt.Errorf("Expected a command")
+	}
+	if output := options["output"].(string); len(output) > 0 {
+		err = checkOutputFormat(output)
+		if err != nil {
+			return err
+		}
+		defer func(previous string) { sh.state.output = previous }(sh.state.output)
+		sh.state.output = output
+	}
+	if user := options["user"].(string); len(user) > 0 {
+		// Unless the command itself changed the user, as login does
+		defer func(previous string) {
+			if sh.state.config.Current_user_name == user {
+				sh.state.config.Current_user_name = previous
+			}
+		}(sh.state.config.Current_user_name)
+		sh.state.config.Current_user_name = user
+	}
+
+	switch words[0] {
+	case "exit", "quit":
+		return io.EOF
+	case "user":
+		if len(words) != 2 {
+			return fmt.Errorf("Expected 1 argument(s): <username>")
+		}
+		user, err := sh.state.database.GetUser(context.Background(), words[1])
+		if err != nil {
+			return err
+		}
+		sh.state.config.Current_user_name = user.Name
+		fmt.Printf("Acting as %v until the shell exits, use `login` to save this\n", user.Name)
+		return nil
+	case "shell":
+		return fmt.Errorf("Already in the shell")
+	}
+
+	return sh.state.commands.run(sh.state, command{name: words[0], args: words[1:]})
+}
+
+// Read commands from a terminal with line editing, history and completion
+func (sh *shell) interactive(fd int) error {
+	historyFile, err := sh.state.config.ShellHistoryFile()
+	if err != nil {
+		return err
+	}
+	history, err := openShellHistory(historyFile)
+	if err != nil {
+		return fmt.Errorf("Opening shell history: %w", err)
+	}
+	defer history.Close()
+
+	sh.terminal = term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, os.Stdout}, "")
+	sh.terminal.History = history
+	sh.terminal.AutoCompleteCallback = sh.autoComplete
+
+	fmt.Printf("Type `help` for a list of commands, `user <name>` to act as someone else,\n" +
+		"and `exit` or Ctrl-D to leave\n")
+	for {
+		width, height, err := term.GetSize(fd)
+		if err == nil && width > 0 {
+			sh.terminal.SetSize(width, height)
+		}
+		sh.terminal.SetPrompt(sh.prompt())
+
+		// Only raw while reading, so that commands print as usual
+		oldState, err := term.MakeRaw(fd)
+		if err != nil {
+			return err
+		}
+		line, err := sh.terminal.ReadLine()
+		term.Restore(fd, oldState)
+		if errors.Is(err, io.EOF) {
+			fmt.Printf("\n")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		err = sh.runLine(line)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+	}
+}
+
+// Run commands piped in, one per line, carrying on after errors
+func (sh *shell) script(r io.Reader) error {
+	failed := 0
+	scanner := bufio.NewScanner(r)
+	// Commands that ask questions read the answers from the script too,
+	// rather than from a second reader that would miss what this one has
+	// buffered
+	sh.state.input = scanner
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		err := sh.runLine(line)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			failed++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%v command(s) failed", failed)
+	}
+	return nil
+}
+
+// Keep the database connection and config between commands, instead of
+// starting gator once for each
+func handlerShell(s *state, cmd command) error {
+	sh := &shell{state: s}
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return sh.script(os.Stdin)
+	}
+	return sh.interactive(fd)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSplitShellLine(t *testing.T) {
+	tests := []struct {
+		line  string
+		words []string
+		err   string
+	}{
+		{"", []string{}, ""},
+		{"  browse   5  ", []string{"browse", "5"}, ""},
+		{"addfeed 'My feed' https://example.com/", []string{"addfeed", "My feed", "https://example.com/"}, ""},
+		{`addfeed "My feed"`, []string{"addfeed", "My feed"}, ""},
+		{`a "it's" 'say "hi"'`, []string{"a", "it's", `say "hi"`}, ""},
+		{`a\ b c`, []string{"a b", "c"}, ""},
+		{`"a \"b\""`, []string{`a "b"`}, ""},
+		{`'a\b'`, []string{`a\b`}, ""},
+		{`a "" ''`, []string{"a", "", ""}, ""},
+		{`pre"fix"ed`, []string{"prefixed"}, ""},
+		{"a\tb", []string{"a", "b"}, ""},
+		{`a "b`, nil, `Unterminated " quote`},
+		{`a 'b`, nil, "Unterminated ' quote"},
+		{`a \`, nil, `Nothing after \ to escape`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.line, func(t *testing.T) {
+			words, err := splitShellLine(test.line)
+			if len(test.err) > 0 {
+				if err == nil || err.Error() != test.err {
+					t.Errorf("Error is %v, expected %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("splitShellLine: %v", err)
+			}
+			if !slices.Equal(words, test.words) {
+				t.Errorf("splitShellLine(%q) = %q, expected %q", test.line, words, test.words)
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+-- name: CreateEnclosure :one
+INSERT INTO enclosures (id, created_at, updated_at, url, mime_type, length,
+    duration, post_id)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8
+)
+ON CONFLICT (post_id, url) DO UPDATE
+SET updated_at = excluded.updated_at, mime_type = excluded.mime_type,
+    length = excluded.length, duration = excluded.duration
+RETURNING *;
+
+-- name: DeleteStaleEnclosures :exec
+DELETE FROM enclosures
+WHERE post_id = sqlc.arg(post_id) AND NOT (url = ANY(sqlc.arg(urls)::text[]));
+
+-- name: GetEnclosuresForPost :many
+SELECT * FROM enclosures
+WHERE post_id = $1
+ORDER BY created_at ASC;
@@ -0,0 +1,31 @@
+-- name: SetFeedCredential :one
+INSERT INTO feed_credentials (id, created_at, updated_at, kind, name, value,
+    feed_id)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7
+)
+ON CONFLICT (feed_id, kind, name) DO UPDATE
+SET value = excluded.value, updated_at = excluded.updated_at
+RETURNING *;
+
+-- name: GetFeedCredentials :many
+SELECT * FROM feed_credentials
+WHERE feed_id = $1
+ORDER BY kind, name;
+
+-- name: DeleteFeedCredentials :exec
+DELETE FROM feed_credentials
+WHERE feed_id = $1;
+
+-- name: MoveFeedCredentials :exec
+UPDATE feed_credentials
+SET feed_id = sqlc.arg(new_feed_id)
+WHERE feed_id = sqlc.arg(old_feed_id) AND (kind, name) NOT IN (
+    SELECT kind, name FROM feed_credentials
+    WHERE feed_id = sqlc.arg(new_feed_id));
@@ -0,0 +1,21 @@
+-- name: CreateFeedEvent :one
+INSERT INTO feed_events (id, created_at, updated_at, kind, detail, feed_id)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
+)
+RETURNING *;
+
+-- name: GetFeedEvents :many
+SELECT * FROM feed_events
+WHERE feed_id = $1
+ORDER BY created_at ASC;
+
+-- name: MoveFeedEvents :exec
+UPDATE feed_events
+SET feed_id = sqlc.arg(new_feed_id)
+WHERE feed_id = sqlc.arg(old_feed_id);
@@ -0,0 +1,30 @@
+-- name: CreateFeedFetch :one
+INSERT INTO feed_fetches (id, created_at, updated_at, status_code, content_encoding,
+    bytes_transferred, body_size, feed_id)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8
+)
+RETURNING *;
+
+-- name: GetFeedBandwidth :many
+SELECT feeds.name, feeds.url,
+    COUNT(feed_fetches.id) AS fetches,
+    COALESCE(SUM(feed_fetches.bytes_transferred), 0)::BIGINT AS bytes_transferred,
+    COALESCE(SUM(feed_fetches.body_size), 0)::BIGINT AS body_size
+FROM feeds
+LEFT JOIN feed_fetches
+    ON feed_fetches.feed_id = feeds.id AND feed_fetches.created_at >= $1
+GROUP BY feeds.id
+ORDER BY bytes_transferred DESC, feeds.name ASC;
+
+-- name: MoveFeedFetches :exec
+UPDATE feed_fetches
+SET feed_id = sqlc.arg(new_feed_id)
+WHERE feed_id = sqlc.arg(old_feed_id);
@@ -28,3 +28,10 @@ WHERE users.id = $1;
 DELETE FROM feed_follows
 WHERE feed_follows.user_id = $1 AND feed_follows.feed_id = $2
 RETURNING *;
+
+-- name: MoveFeedFollows :exec
+UPDATE feed_follows
+SET feed_id = sqlc.arg(new_feed_id)
+WHERE feed_id = sqlc.arg(old_feed_id) AND user_id NOT IN (
+    SELECT user_id FROM feed_follows
+    WHERE feed_id = sqlc.arg(new_feed_id));
@@ -11,7 +11,9 @@ VALUES (
 RETURNING *;
 
 -- name: GetFeeds :many
-SELECT feeds.name AS name, feeds.url AS url, users.name AS username
+SELECT feeds.name AS name, feeds.url AS url, users.name AS username,
+    feeds.title, feeds.site_url, feeds.description, feeds.language,
+    feeds.image_url, feeds.id
 FROM feeds
 LEFT JOIN users
 ON feeds.user_id = users.id;
@@ -20,3 +22,20 @@ ON feeds.user_id = users.id;
 SELECT name, id
 FROM feeds
 WHERE url = $1;
+
+-- name: UpdateFeedMetadata :one
+UPDATE feeds
+SET title = $1, site_url = $2, description = $3, language = $4,
+    image_url = $5, updated_at = $6
+WHERE id = $7
+RETURNING *;
+
+-- name: UpdateFeedURL :one
+UPDATE feeds
+SET url = $1, updated_at = $2
+WHERE id = $3
+RETURNING *;
+
+-- name: DeleteFeed :exec
+DELETE FROM feeds
+WHERE id = $1;
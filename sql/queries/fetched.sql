@@ -5,6 +5,38 @@ WHERE id = $2
 RETURNING *;
 
 
--- name: GetStalestFeed :one
+-- name: GetNextFeedToFetch :one
 SELECT * FROM feeds
-ORDER BY last_fetched_at ASC NULLS FIRST LIMIT 1;
+WHERE next_fetch_at IS NULL OR next_fetch_at <= $1
+ORDER BY next_fetch_at ASC NULLS FIRST, last_fetched_at ASC NULLS FIRST
+LIMIT 1;
+
+-- name: SetFeedNextFetch :one
+UPDATE feeds
+SET next_fetch_at = $1, poll_interval_seconds = $2
+WHERE id = $3
+RETURNING *;
+
+-- name: GetRecentPostTimes :many
+SELECT published_at FROM posts
+WHERE feed_id = $1
+ORDER BY published_at DESC LIMIT $2;
+
+-- name: GetFeedSchedule :many
+SELECT name, url, last_fetched_at, next_fetch_at, poll_interval_seconds
+FROM feeds
+ORDER BY next_fetch_at ASC NULLS FIRST;
+
+-- name: GetAllFeeds :many
+SELECT * FROM feeds
+ORDER BY name ASC;
+
+-- name: GetFollowedFeeds :many
+SELECT feeds.* FROM feeds
+INNER JOIN feed_follows ON feed_follows.feed_id = feeds.id
+WHERE feed_follows.user_id = $1
+ORDER BY feeds.name ASC;
+
+-- name: CountOverdueFeeds :one
+SELECT COUNT(*) FROM feeds
+WHERE next_fetch_at IS NULL OR next_fetch_at <= $1;
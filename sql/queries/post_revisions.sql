@@ -0,0 +1,19 @@
+-- name: CreatePostRevision :one
+INSERT INTO post_revisions (id, created_at, updated_at, title, url,
+    description, content_hash, post_id)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8
+)
+RETURNING *;
+
+-- name: GetPostRevisions :many
+SELECT * FROM post_revisions
+WHERE post_id = $1
+ORDER BY created_at ASC;
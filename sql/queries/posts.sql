@@ -1,6 +1,6 @@
--- name: CreatePost :one
+-- name: UpsertPost :one
 INSERT INTO posts (id, created_at, updated_at, title, url, description,
-    published_at, feed_id)
+    published_at, feed_id, guid, content_hash)
 VALUES (
     $1,
     $2,
@@ -9,12 +9,26 @@ VALUES (
     $5,
     $6,
     $7,
-    $8
+    $8,
+    $9,
+    $10
 )
+ON CONFLICT (feed_id, guid) DO UPDATE
+SET title = excluded.title, url = excluded.url,
+    description = excluded.description, content_hash = excluded.content_hash,
+    legacy_hash = FALSE,
+    updated_at = CASE
+        WHEN posts.content_hash <> excluded.content_hash AND NOT posts.legacy_hash
+        THEN excluded.updated_at
+        ELSE posts.updated_at
+    END
 RETURNING *;
 
 -- name: GetPostsForUser :many
-SELECT posts.*, feeds.name as feed_name FROM posts
+SELECT posts.*, feeds.name as feed_name,
+    (SELECT COUNT(*) FROM post_revisions
+    WHERE post_revisions.post_id = posts.id) AS revisions
+FROM posts
 JOIN feed_follows
 ON posts.feed_id = feed_follows.feed_id
 JOIN users
@@ -23,3 +37,29 @@ JOIN feeds
 ON feeds.id = feed_follows.feed_id
 WHERE users.id = $1
 ORDER BY posts.published_at DESC LIMIT $2;
+
+-- name: GetPost :one
+SELECT * FROM posts
+WHERE id = $1;
+
+-- name: GetPostByURL :one
+SELECT * FROM posts
+WHERE url = $1
+ORDER BY published_at DESC LIMIT 1;
+
+-- name: GetPostByGUID :one
+SELECT * FROM posts
+WHERE feed_id = $1 AND guid = $2
+FOR UPDATE;
+
+-- name: MovePosts :exec
+UPDATE posts
+SET feed_id = sqlc.arg(new_feed_id)
+WHERE feed_id = sqlc.arg(old_feed_id) AND guid NOT IN (
+    SELECT guid FROM posts
+    WHERE feed_id = sqlc.arg(new_feed_id));
+
+-- name: UpdatePostGUID :exec
+UPDATE posts
+SET guid = $2
+WHERE id = $1;
@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE enclosures(
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    url TEXT NOT NULL,
+    mime_type TEXT,
+    length BIGINT,
+    duration INTEGER,
+    post_id UUID NOT NULL REFERENCES posts ON DELETE CASCADE,
+    CONSTRAINT fk_post_id FOREIGN KEY (post_id) REFERENCES posts(id),
+    UNIQUE(post_id, url));
+
+-- +goose Down
+DROP TABLE enclosures;
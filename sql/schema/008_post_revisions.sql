@@ -0,0 +1,37 @@
+-- +goose Up
+ALTER TABLE posts
+ADD COLUMN content_hash TEXT;
+
+-- Posts saved before now had their descriptions cleaned up differently, so
+-- the hashes worked out here will not match when each is next fetched
+ALTER TABLE posts
+ADD COLUMN legacy_hash BOOLEAN NOT NULL DEFAULT FALSE;
+
+UPDATE posts
+SET content_hash = encode(sha256(convert_to(
+    coalesce(title, '') || E'\n' || url || E'\n' || coalesce(description, ''),
+    'UTF8')), 'hex'),
+    legacy_hash = TRUE;
+
+ALTER TABLE posts
+ALTER COLUMN content_hash SET NOT NULL;
+
+CREATE TABLE post_revisions(
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    title TEXT,
+    url TEXT NOT NULL,
+    description TEXT,
+    content_hash TEXT NOT NULL,
+    post_id UUID NOT NULL REFERENCES posts ON DELETE CASCADE,
+    CONSTRAINT fk_post_id FOREIGN KEY (post_id) REFERENCES posts(id));
+
+-- +goose Down
+DROP TABLE post_revisions;
+
+ALTER TABLE posts
+DROP COLUMN legacy_hash;
+
+ALTER TABLE posts
+DROP COLUMN content_hash;
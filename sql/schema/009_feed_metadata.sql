@@ -0,0 +1,15 @@
+-- +goose Up
+ALTER TABLE feeds
+ADD COLUMN title TEXT,
+ADD COLUMN site_url TEXT,
+ADD COLUMN description TEXT,
+ADD COLUMN language TEXT,
+ADD COLUMN image_url TEXT;
+
+-- +goose Down
+ALTER TABLE feeds
+DROP COLUMN title,
+DROP COLUMN site_url,
+DROP COLUMN description,
+DROP COLUMN language,
+DROP COLUMN image_url;
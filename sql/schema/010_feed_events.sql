@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE TABLE feed_events(
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    kind TEXT NOT NULL,
+    detail TEXT NOT NULL,
+    feed_id UUID NOT NULL REFERENCES feeds ON DELETE CASCADE,
+    CONSTRAINT fk_feed_id FOREIGN KEY (feed_id) REFERENCES feeds(id));
+
+-- +goose Down
+DROP TABLE feed_events;
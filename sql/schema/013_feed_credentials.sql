@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE feed_credentials(
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    kind TEXT NOT NULL,
+    name TEXT NOT NULL,
+    value TEXT NOT NULL,
+    feed_id UUID NOT NULL REFERENCES feeds ON DELETE CASCADE,
+    CONSTRAINT fk_feed_id FOREIGN KEY (feed_id) REFERENCES feeds(id),
+    UNIQUE(feed_id, kind, name));
+
+-- +goose Down
+DROP TABLE feed_credentials;
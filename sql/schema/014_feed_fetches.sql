@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE feed_fetches(
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    status_code INTEGER NOT NULL,
+    content_encoding TEXT NOT NULL,
+    bytes_transferred BIGINT NOT NULL,
+    body_size BIGINT NOT NULL,
+    feed_id UUID NOT NULL REFERENCES feeds ON DELETE CASCADE,
+    CONSTRAINT fk_feed_id FOREIGN KEY (feed_id) REFERENCES feeds(id));
+
+-- +goose Down
+DROP TABLE feed_fetches;